@@ -7,6 +7,7 @@ require (
 	github.com/google/go-github/v50 v50.2.0
 	github.com/shurcooL/githubv4 v0.0.0-20230305132112-efb623903184
 	golang.org/x/oauth2 v0.11.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
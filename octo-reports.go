@@ -2,7 +2,6 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"log"
 	"os"
 
@@ -54,6 +53,28 @@ func main() {
 	repoCommand := flag.NewFlagSet("repo-report", flag.ExitOnError)
 	collaboratorCommand := flag.NewFlagSet("collaborator-report", flag.ExitOnError)
 	packageCommand := flag.NewFlagSet("package-report", flag.ExitOnError)
+	dormantCommand := flag.NewFlagSet("dormant-users", flag.ExitOnError)
+	licenseCommand := flag.NewFlagSet("license-report", flag.ExitOnError)
+	branchProtectionCommand := flag.NewFlagSet("branch-protection-report", flag.ExitOnError)
+	securityCommand := flag.NewFlagSet("security-report", flag.ExitOnError)
+	vulnerabilityCommand := flag.NewFlagSet("vulnerability-report", flag.ExitOnError)
+	teamAccessCommand := flag.NewFlagSet("team-access-report", flag.ExitOnError)
+	accessMatrixCommand := flag.NewFlagSet("access-matrix", flag.ExitOnError)
+	userCommand := flag.NewFlagSet("user", flag.ExitOnError)
+	repoLookupCommand := flag.NewFlagSet("repo", flag.ExitOnError)
+	orgSettingsCommand := flag.NewFlagSet("org-settings-report", flag.ExitOnError)
+	staleCommand := flag.NewFlagSet("stale-repos", flag.ExitOnError)
+	codeownersCommand := flag.NewFlagSet("codeowners-report", flag.ExitOnError)
+	actionsCommand := flag.NewFlagSet("actions-report", flag.ExitOnError)
+	secretsCommand := flag.NewFlagSet("secrets-inventory", flag.ExitOnError)
+	environmentsCommand := flag.NewFlagSet("environments-report", flag.ExitOnError)
+	integrationsCommand := flag.NewFlagSet("integrations-report", flag.ExitOnError)
+	packagePruneCommand := flag.NewFlagSet("package-prune", flag.ExitOnError)
+	auditLogCommand := flag.NewFlagSet("audit-log", flag.ExitOnError)
+	copilotCommand := flag.NewFlagSet("copilot-report", flag.ExitOnError)
+	billingCommand := flag.NewFlagSet("billing-report", flag.ExitOnError)
+	propertiesCommand := flag.NewFlagSet("properties-report", flag.ExitOnError)
+	propertiesApplyCommand := flag.NewFlagSet("properties-apply", flag.ExitOnError)
 	//loginCommand := flag.NewFlagSet("login", flag.ExitOnError)
 
 	// Enterprise flags
@@ -67,18 +88,107 @@ func main() {
 
 	// Repo flags
 	repoEnterpriseSlugPointer := repoCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+	repoFieldsPointer := repoCommand.String("fields", "", "A comma separated list of optional fields to add to the report, or all. Can be any of: default_branch, language, languages, disk_usage_kb, license, description, homepage, is_template, is_mirror, is_empty, open_issues, open_prs.")
 
 	// Collaborator flags
 	collaboratorOrgPointer := collaboratorCommand.String("org", "", "(Required) The login of the organization to run the report for.")
 
 	// Package flags
-	packageOrgPointer := packageCommand.String("org", "", "(Required) The login of the organization to run the report for.")
+	packageOrgPointer := packageCommand.String("org", "", "The login of the organization to run the report for. Either org or enterprise-slug is required.")
+	packageEnterpriseSlugPointer := packageCommand.String("enterprise-slug", "", "The slug of the enterprise to run the report for, covering every organization in it.")
+
+	// Dormant user flags
+	dormantEnterpriseSlugPointer := dormantCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+	dormantInactiveDaysPointer := dormantCommand.Int("inactive-days", 90, "The number of days without activity after which a user is considered dormant.")
+
+	// License flags
+	licenseEnterpriseSlugPointer := licenseCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Branch protection flags
+	branchProtectionEnterpriseSlugPointer := branchProtectionCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Security flags
+	securityEnterpriseSlugPointer := securityCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Vulnerability flags
+	vulnerabilityEnterpriseSlugPointer := vulnerabilityCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Team access flags
+	teamAccessEnterpriseSlugPointer := teamAccessCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Access matrix flags
+	accessMatrixOrgPointer := accessMatrixCommand.String("org", "", "(Required) The login of the organization to run the report for.")
+
+	// User flags
+	userLoginPointer := userCommand.String("login", "", "(Required) The login of the user to look up.")
+	userEnterpriseSlugPointer := userCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to search.")
+	userOrgPointer := userCommand.String("org", "", "The login of a single organization to search instead of the whole enterprise.")
+
+	// Repo lookup flags
+	repoLookupNamePointer := repoLookupCommand.String("name", "", "(Required) The repository to look up, in the form org/repo.")
+
+	// Org settings flags
+	orgSettingsEnterpriseSlugPointer := orgSettingsCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Stale repo flags
+	staleEnterpriseSlugPointer := staleCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+	staleDaysPointer := staleCommand.Int("days", 365, "The number of days without a push after which a repository is considered stale.")
+	staleArchivePointer := staleCommand.String("archive", "", "Archive the candidates. Can be one of: dry-run, apply.")
+
+	// Codeowners flags
+	codeownersEnterpriseSlugPointer := codeownersCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Actions flags
+	actionsEnterpriseSlugPointer := actionsCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Secrets inventory flags
+	secretsEnterpriseSlugPointer := secretsCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+	secretsMaxAgeDaysPointer := secretsCommand.Int("max-age-days", 90, "The number of days without an update after which a secret is flagged for rotation.")
+
+	// Environments flags
+	environmentsEnterpriseSlugPointer := environmentsCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Integrations flags
+	integrationsEnterpriseSlugPointer := integrationsCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Package prune flags
+	packagePruneOrgPointer := packagePruneCommand.String("org", "", "The login of the organization to prune. Either org or enterprise-slug is required.")
+	packagePruneEnterpriseSlugPointer := packagePruneCommand.String("enterprise-slug", "", "The slug of the enterprise to prune, covering every organization in it.")
+	packagePruneOlderThanDaysPointer := packagePruneCommand.Int("older-than-days", 0, "Prune versions created more than this many days ago.")
+	packagePruneKeepLastPointer := packagePruneCommand.Int("keep-last", 0, "Prune versions beyond the newest this many versions of each package.")
+	packagePruneIncludeUnknownDownloadsPointer := packagePruneCommand.Bool("include-unknown-downloads", false, "Also prune versions whose download count is not known. Container versions are never pruned this way.")
+	packagePruneApplyPointer := packagePruneCommand.Bool("apply", false, "Delete the versions instead of only reporting them.")
+
+	// Audit log flags
+	auditLogEnterpriseSlugPointer := auditLogCommand.String("enterprise-slug", "", "The slug of the enterprise to export the audit log of. Either enterprise-slug or org is required.")
+	auditLogOrgPointer := auditLogCommand.String("org", "", "The login of the organization to export the audit log of.")
+	auditLogPhrasePointer := auditLogCommand.String("phrase", "", "An audit log search phrase, e.g. action:repo.destroy or actor:<login>.")
+	auditLogSincePointer := auditLogCommand.String("since", "", "Only export events created at or after this date (YYYY-MM-DD) or RFC 3339 timestamp.")
+	auditLogUntilPointer := auditLogCommand.String("until", "", "Only export events created at or before this date (YYYY-MM-DD) or RFC 3339 timestamp.")
+	auditLogFormatPointer := auditLogCommand.String("format", "ndjson", "The output format. Can be one of: ndjson, csv.")
+	auditLogOutputPointer := auditLogCommand.String("output", "", "The file to write the events to. Defaults to audit-log.ndjson or audit-log.csv.")
+	auditLogStatePointer := auditLogCommand.String("state", "", "A file that remembers the last exported event, so that each run only appends newer events to the output.")
+
+	// Copilot flags
+	copilotEnterpriseSlugPointer := copilotCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+	copilotInactiveDaysPointer := copilotCommand.Int("inactive-days", 30, "The number of days without Copilot activity after which a seat is considered unused.")
+
+	// Billing report flags
+	billingEnterpriseSlugPointer := billingCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+
+	// Properties report flags
+	propertiesEnterpriseSlugPointer := propertiesCommand.String("enterprise-slug", "", "(Required) The slug of the enterprise to run the report for.")
+	propertiesFieldsPointer := propertiesCommand.String("fields", "", "A comma separated list of optional repo report fields to add to the report, or all.")
+
+	// Properties apply flags
+	propertiesApplyFromPointer := propertiesApplyCommand.String("from", "", "(Required) A CSV file with owner and name columns and one column per custom property, such as repo-properties.csv.")
+	propertiesApplyApplyPointer := propertiesApplyCommand.Bool("apply", false, "Write the changes instead of only printing them.")
 
 	// Login flags
 	//loginClientIdPointer := loginCommand.String("client-id", "", "(Required) The client ID of the GitHub App to use for authentication.")
 
 	if len(os.Args) < 2 {
-		log.Fatalf("Please specify a subcommand. Can be one of: enterprise-report, org-report, team-report, repo-report, collaborator-report")
+		log.Fatalf("Please specify a subcommand. Can be one of: enterprise-report, org-report, team-report, repo-report, collaborator-report, package-report, dormant-users, license-report, branch-protection-report, security-report, vulnerability-report, team-access-report, access-matrix, user, repo, org-settings-report, stale-repos, codeowners-report, actions-report, secrets-inventory, environments-report, integrations-report, package-prune, audit-log, copilot-report, billing-report, properties-report, properties-apply")
 	}
 
 	// Load the config file
@@ -96,19 +206,172 @@ func main() {
 	case "team-report":
 		parseRequiredFlags(teamCommand, []string{"enterprise-slug"})
 		client := octoreports.NewV4Client(config.URL, config.Token)
-		octoreports.GenerateTeamReport(*teamEnterpriseSlugPointer, client)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateTeamReport(*teamEnterpriseSlugPointer, client, restClient)
 	case "repo-report":
 		parseRequiredFlags(repoCommand, []string{"enterprise-slug"})
+		fields, err := octoreports.ParseRepoFields(*repoFieldsPointer)
+		if err != nil {
+			repoCommand.PrintDefaults()
+			log.Fatal(err)
+		}
 		client := octoreports.NewV4Client(config.URL, config.Token)
-		octoreports.GenerateRepoReport(*repoEnterpriseSlugPointer, client)
+		octoreports.GenerateRepoReport(*repoEnterpriseSlugPointer, fields, client)
 	case "collaborator-report":
 		parseRequiredFlags(collaboratorCommand, []string{"org"})
 		client := octoreports.NewV4Client(config.URL, config.Token)
 		octoreports.GenerateCollaboratorReport(*collaboratorOrgPointer, client)
 	case "package-report":
-		parseRequiredFlags(packageCommand, []string{"org"})
+		packageCommand.Parse(os.Args[2:])
+		if (*packageOrgPointer == "") == (*packageEnterpriseSlugPointer == "") {
+			packageCommand.PrintDefaults()
+			log.Fatalf("exactly one of org or enterprise-slug is required")
+		}
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		if *packageEnterpriseSlugPointer != "" {
+			octoreports.GenerateEnterprisePackageReport(*packageEnterpriseSlugPointer, client, restClient)
+		} else {
+			octoreports.GenerateOrgPackageReport(*packageOrgPointer, client, restClient)
+		}
+	case "dormant-users":
+		parseRequiredFlags(dormantCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateDormantUsersReport(*dormantEnterpriseSlugPointer, *dormantInactiveDaysPointer, client, restClient)
+	case "license-report":
+		parseRequiredFlags(licenseCommand, []string{"enterprise-slug"})
 		client := octoreports.NewV4Client(config.URL, config.Token)
-		octoreports.GenerateOrgPackageReport(*packageOrgPointer, client)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateLicenseReport(*licenseEnterpriseSlugPointer, client, restClient)
+	case "branch-protection-report":
+		parseRequiredFlags(branchProtectionCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		octoreports.GenerateBranchProtectionReport(*branchProtectionEnterpriseSlugPointer, client)
+	case "security-report":
+		parseRequiredFlags(securityCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateSecurityReport(*securityEnterpriseSlugPointer, client, restClient)
+	case "vulnerability-report":
+		parseRequiredFlags(vulnerabilityCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateVulnerabilityReport(*vulnerabilityEnterpriseSlugPointer, client, restClient)
+	case "team-access-report":
+		parseRequiredFlags(teamAccessCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		octoreports.GenerateTeamAccessReport(*teamAccessEnterpriseSlugPointer, client)
+	case "access-matrix":
+		parseRequiredFlags(accessMatrixCommand, []string{"org"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateAccessMatrixReport(*accessMatrixOrgPointer, client, restClient)
+	case "user":
+		parseRequiredFlags(userCommand, []string{"login", "enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		octoreports.GenerateUserAccessReport(*userEnterpriseSlugPointer, *userLoginPointer, *userOrgPointer, client)
+	case "repo":
+		parseRequiredFlags(repoLookupCommand, []string{"name"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateRepoAccessReport(*repoLookupNamePointer, client, restClient)
+	case "org-settings-report":
+		parseRequiredFlags(orgSettingsCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateOrgSettingsReport(*orgSettingsEnterpriseSlugPointer, client, restClient)
+	case "stale-repos":
+		parseRequiredFlags(staleCommand, []string{"enterprise-slug"})
+		if *staleArchivePointer != "" && *staleArchivePointer != "dry-run" && *staleArchivePointer != "apply" {
+			staleCommand.PrintDefaults()
+			log.Fatalf("archive must be one of: dry-run, apply")
+		}
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateStaleReposReport(*staleEnterpriseSlugPointer, *staleDaysPointer, *staleArchivePointer, client, restClient)
+	case "codeowners-report":
+		parseRequiredFlags(codeownersCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		octoreports.GenerateCodeownersReport(*codeownersEnterpriseSlugPointer, client)
+	case "actions-report":
+		parseRequiredFlags(actionsCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateActionsReport(*actionsEnterpriseSlugPointer, client, restClient)
+	case "secrets-inventory":
+		parseRequiredFlags(secretsCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateSecretsInventoryReport(*secretsEnterpriseSlugPointer, *secretsMaxAgeDaysPointer, client, restClient)
+	case "environments-report":
+		parseRequiredFlags(environmentsCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateEnvironmentsReport(*environmentsEnterpriseSlugPointer, client, restClient)
+	case "integrations-report":
+		parseRequiredFlags(integrationsCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateIntegrationsReport(*integrationsEnterpriseSlugPointer, client, restClient)
+	case "package-prune":
+		packagePruneCommand.Parse(os.Args[2:])
+		if (*packagePruneOrgPointer == "") == (*packagePruneEnterpriseSlugPointer == "") {
+			packagePruneCommand.PrintDefaults()
+			log.Fatalf("exactly one of org or enterprise-slug is required")
+		}
+		if *packagePruneOlderThanDaysPointer <= 0 && *packagePruneKeepLastPointer <= 0 {
+			packagePruneCommand.PrintDefaults()
+			log.Fatalf("at least one of older-than-days or keep-last is required")
+		}
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GeneratePackagePruneReport(*packagePruneOrgPointer, *packagePruneEnterpriseSlugPointer, *packagePruneOlderThanDaysPointer, *packagePruneKeepLastPointer, *packagePruneIncludeUnknownDownloadsPointer, *packagePruneApplyPointer, client, restClient)
+	case "audit-log":
+		auditLogCommand.Parse(os.Args[2:])
+		if (*auditLogEnterpriseSlugPointer == "") == (*auditLogOrgPointer == "") {
+			auditLogCommand.PrintDefaults()
+			log.Fatalf("exactly one of enterprise-slug or org is required")
+		}
+		if *auditLogFormatPointer != "ndjson" && *auditLogFormatPointer != "csv" {
+			auditLogCommand.PrintDefaults()
+			log.Fatalf("format must be one of: ndjson, csv")
+		}
+		if *auditLogOutputPointer == "" {
+			*auditLogOutputPointer = "audit-log." + *auditLogFormatPointer
+		}
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		err := octoreports.ExportAuditLog(*auditLogEnterpriseSlugPointer, *auditLogOrgPointer, *auditLogPhrasePointer, *auditLogSincePointer, *auditLogUntilPointer, *auditLogFormatPointer, *auditLogOutputPointer, *auditLogStatePointer, restClient)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "copilot-report":
+		parseRequiredFlags(copilotCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateCopilotReport(*copilotEnterpriseSlugPointer, *copilotInactiveDaysPointer, client, restClient)
+	case "billing-report":
+		parseRequiredFlags(billingCommand, []string{"enterprise-slug"})
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GenerateBillingReport(*billingEnterpriseSlugPointer, client, restClient)
+	case "properties-report":
+		parseRequiredFlags(propertiesCommand, []string{"enterprise-slug"})
+		fields, err := octoreports.ParseRepoFields(*propertiesFieldsPointer)
+		if err != nil {
+			propertiesCommand.PrintDefaults()
+			log.Fatal(err)
+		}
+		client := octoreports.NewV4Client(config.URL, config.Token)
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		octoreports.GeneratePropertiesReport(*propertiesEnterpriseSlugPointer, fields, client, restClient)
+	case "properties-apply":
+		parseRequiredFlags(propertiesApplyCommand, []string{"from"})
+		restClient := octoreports.NewV3Client(config.URL, config.Token)
+		err := octoreports.ApplyRepoProperties(*propertiesApplyFromPointer, *propertiesApplyApplyPointer, restClient)
+		if err != nil {
+			log.Fatal(err)
+		}
 	// TODO: Implement login once Enterprise Apps are GA
 	/*
 		case "login":
@@ -0,0 +1,361 @@
+package octoreports
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+// permissionRanks orders the repository permissions from least to most privileged.
+var permissionRanks = map[string]int{
+	"READ":     1,
+	"TRIAGE":   2,
+	"WRITE":    3,
+	"MAINTAIN": 4,
+	"ADMIN":    5,
+}
+
+// permissionRank returns the privilege level of a repository permission. GraphQL returns
+// upper case permissions and REST lower case, so both are accepted.
+func permissionRank(permission string) int {
+	return permissionRanks[strings.ToUpper(permission)]
+}
+
+// Grant is a single way a user is given a permission on a repository.
+type Grant struct {
+	Permission string
+	Source     string
+}
+
+// EffectiveAccess is a user's highest permission on a repository and every grant behind it.
+type EffectiveAccess struct {
+	Login      string
+	Repo       string
+	Permission string
+	Grants     []Grant
+}
+
+func (a *EffectiveAccess) add(permission, source string) {
+	permission = strings.ToUpper(permission)
+	if permissionRank(permission) == 0 {
+		return
+	}
+	a.Grants = append(a.Grants, Grant{Permission: permission, Source: source})
+	if permissionRank(permission) > permissionRank(a.Permission) {
+		a.Permission = permission
+	}
+}
+
+// Sources formats the grants as source:permission, highest permission first.
+func (a *EffectiveAccess) Sources() string {
+	sort.SliceStable(a.Grants, func(i, j int) bool {
+		return permissionRank(a.Grants[i].Permission) > permissionRank(a.Grants[j].Permission)
+	})
+
+	sources := []string{}
+	for _, grant := range a.Grants {
+		sources = append(sources, grant.Source+":"+grant.Permission)
+	}
+	return strings.Join(sources, ", ")
+}
+
+// getOrgAccessMatrix resolves the effective permission of every user on every repository in an
+// organization from direct collaborator grants, team membership, the org base permission and
+// org admin status. The result is keyed by repository name and then by login.
+func getOrgAccessMatrix(orgName string, client *githubv4.Client, restClient *github.Client) ([]*Repo, map[string]map[string]*EffectiveAccess, error) {
+
+	repos, err := getOrgRepos(orgName, false, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	members, err := getOrgMembersWithRole(orgName, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	basePermission, err := getOrgBasePermission(orgName, restClient)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	teams, err := getOrgTeams(orgName, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	teamAccess, err := getOrgTeamAccess(orgName, teams, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// team members already include the members of child teams, so only the grants made
+	// to each team directly are needed to cover nested teams
+	teamGrants := map[string][]Grant{}
+	for _, team := range teams {
+		for _, grant := range teamAccess[team.Slug] {
+			if grant.Inherited {
+				continue
+			}
+			for _, member := range team.Members {
+				teamGrants[grant.Repo+"/"+member.Login] = append(teamGrants[grant.Repo+"/"+member.Login], Grant{
+					Permission: grant.Permission,
+					Source:     "team/" + team.Slug,
+				})
+			}
+		}
+	}
+
+	isMember := map[string]bool{}
+	for _, member := range members {
+		isMember[member.Login] = true
+	}
+
+	matrix := map[string]map[string]*EffectiveAccess{}
+	for _, repo := range repos {
+		access := map[string]*EffectiveAccess{}
+		matrix[repo.Name] = access
+
+		lookup := func(login string) *EffectiveAccess {
+			if _, ok := access[login]; !ok {
+				access[login] = &EffectiveAccess{Login: login, Repo: repo.Name}
+			}
+			return access[login]
+		}
+
+		for _, member := range members {
+			if member.Role == "ADMIN" {
+				lookup(member.Login).add("ADMIN", "org_admin")
+			}
+			lookup(member.Login).add(basePermission, "org_base")
+			for _, grant := range teamGrants[repo.Name+"/"+member.Login] {
+				lookup(member.Login).add(grant.Permission, grant.Source)
+			}
+		}
+
+		collaborators, err := getRepoCollaborators(orgName, repo.Name, githubv4.CollaboratorAffiliationDirect, client)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, collaborator := range collaborators {
+			source := "direct"
+			if !isMember[collaborator.Login] {
+				source = "outside_collaborator"
+			}
+			lookup(collaborator.Login).add(collaborator.Permission, source)
+		}
+
+		// members without any permission, e.g. when the base permission is none, are dropped
+		for login, effective := range access {
+			if effective.Permission == "" {
+				delete(access, login)
+			}
+		}
+	}
+
+	return repos, matrix, nil
+}
+
+func GenerateAccessMatrixReport(orgName string, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("access-matrix.csv")
+	if err != nil {
+		fmt.Println("Error creating the CSV file:", err)
+	}
+
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"org", "repo", "login", "permission", "sources"}
+
+	err = writer.Write(header)
+	if err != nil {
+		fmt.Println("Error writing the header row:", err)
+		return nil
+	}
+
+	repos, matrix, err := getOrgAccessMatrix(orgName, client, restClient)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	records := 0
+	for _, repo := range repos {
+		logins := []string{}
+		for login := range matrix[repo.Name] {
+			logins = append(logins, login)
+		}
+		sort.Strings(logins)
+
+		for _, login := range logins {
+			effective := matrix[repo.Name][login]
+			record := []string{
+				orgName,
+				repo.Name,
+				login,
+				effective.Permission,
+				effective.Sources(),
+			}
+
+			err = writer.Write(record)
+			if err != nil {
+				fmt.Println("Error writing the record:", err)
+			}
+			records++
+		}
+	}
+
+	log.Printf("Wrote %d records to access-matrix.csv", records)
+
+	return nil
+}
+
+// teamPath follows a member down from the team that was granted access to the most deeply
+// nested child team they belong to, e.g. engineering/platform/sre. Team members include the
+// members of child teams, so the member is found in every team along the way.
+func teamPath(slug, login string, bySlug map[string]Team, children map[string][]string) string {
+
+	path := slug
+	for {
+		next := ""
+		for _, child := range children[slug] {
+			for _, member := range bySlug[child].Members {
+				if member.Login == login {
+					next = child
+				}
+			}
+		}
+		if next == "" {
+			return path
+		}
+		path += "/" + next
+		slug = next
+	}
+}
+
+// GenerateRepoAccessReport prints every team and person with access to a single repository,
+// with the permission and how the access is granted.
+func GenerateRepoAccessReport(nameWithOwner string, client *githubv4.Client, restClient *github.Client) error {
+
+	orgName, repoName, ok := strings.Cut(nameWithOwner, "/")
+	if !ok {
+		log.Fatalf("%s is not in the form org/repo", nameWithOwner)
+	}
+
+	repoTeams, err := getTeamsRoleForRepo(orgName, repoName, *client)
+	if err != nil {
+		panic(err)
+	}
+
+	allTeams, err := getOrgTeams(orgName, client)
+	if err != nil {
+		panic(err)
+	}
+
+	members, err := getOrgMembersWithRole(orgName, client)
+	if err != nil {
+		panic(err)
+	}
+
+	basePermission, err := getOrgBasePermission(orgName, restClient)
+	if err != nil {
+		panic(err)
+	}
+
+	collaborators, err := getRepoCollaborators(orgName, repoName, githubv4.CollaboratorAffiliationDirect, client)
+	if err != nil {
+		panic(err)
+	}
+
+	bySlug := map[string]Team{}
+	children := map[string][]string{}
+	for _, team := range allTeams {
+		bySlug[team.Slug] = team
+		if team.ParentTeam != "" {
+			children[team.ParentTeam] = append(children[team.ParentTeam], team.Slug)
+		}
+	}
+
+	access := map[string]*EffectiveAccess{}
+	lookup := func(login string) *EffectiveAccess {
+		if _, ok := access[login]; !ok {
+			access[login] = &EffectiveAccess{Login: login, Repo: repoName}
+		}
+		return access[login]
+	}
+
+	isMember := map[string]bool{}
+	for _, member := range members {
+		isMember[member.Login] = true
+		if member.Role == "ADMIN" {
+			lookup(member.Login).add("ADMIN", "org_admin")
+		}
+		lookup(member.Login).add(basePermission, "org_base")
+	}
+
+	// child teams inherit the access of the teams granted on the repository
+	teamLines := []string{}
+	var addTeam func(slug, permission, inheritedFrom string)
+	addTeam = func(slug, permission, inheritedFrom string) {
+		how := "direct"
+		if inheritedFrom != "" {
+			how = "inherited from " + inheritedFrom
+		}
+		teamLines = append(teamLines, fmt.Sprintf("  %s\t%s\t%s", slug, permission, how))
+		for _, child := range children[slug] {
+			addTeam(child, permission, slug)
+		}
+	}
+
+	for _, repoTeam := range repoTeams {
+		// getTeamsRoleForRepo returns the team slug as the name
+		slug := repoTeam.Name
+		addTeam(slug, repoTeam.Role, "")
+
+		for _, member := range bySlug[slug].Members {
+			lookup(member.Login).add(repoTeam.Role, "team/"+teamPath(slug, member.Login, bySlug, children))
+		}
+	}
+
+	for _, collaborator := range collaborators {
+		source := "direct"
+		if !isMember[collaborator.Login] {
+			source = "outside_collaborator"
+		}
+		lookup(collaborator.Login).add(collaborator.Permission, source)
+	}
+
+	logins := []string{}
+	for login, effective := range access {
+		if effective.Permission != "" {
+			logins = append(logins, login)
+		}
+	}
+	sort.Strings(logins)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "Repository:\t%s/%s\n", orgName, repoName)
+	fmt.Fprintf(w, "Base permission:\t%s\n", basePermission)
+
+	fmt.Fprintf(w, "\nTeams (%d):\n", len(teamLines))
+	for _, line := range teamLines {
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintf(w, "\nPeople (%d):\n", len(logins))
+	for _, login := range logins {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", login, access[login].Permission, access[login].Sources())
+	}
+
+	return nil
+}
@@ -0,0 +1,422 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+// ActionsSettings is the Actions policy of an organization or repository.
+type ActionsSettings struct {
+	Enabled                      string
+	AllowedActions               string
+	GithubOwnedAllowed           bool
+	VerifiedAllowed              bool
+	PatternsAllowed              []string
+	ForkPRApproval               string
+	DefaultWorkflowPermissions   string
+	CanApprovePullRequestReviews bool
+}
+
+// actionsSettingsColumns are the headers for the values returned by ActionsSettings.Values.
+var actionsSettingsColumns = []string{"enabled", "allowed_actions", "github_owned_allowed", "verified_allowed", "patterns_allowed", "fork_pr_approval", "default_workflow_permissions", "can_approve_pull_request_reviews"}
+
+func (s *ActionsSettings) Values() []string {
+	return []string{
+		s.Enabled,
+		s.AllowedActions,
+		fmt.Sprintf("%t", s.GithubOwnedAllowed),
+		fmt.Sprintf("%t", s.VerifiedAllowed),
+		strings.Join(s.PatternsAllowed, " "),
+		s.ForkPRApproval,
+		s.DefaultWorkflowPermissions,
+		fmt.Sprintf("%t", s.CanApprovePullRequestReviews),
+	}
+}
+
+// getWorkflowPermissions adds the default GITHUB_TOKEN permissions and the fork pull request
+// approval policy to settings. go-github does not model these endpoints, so path is the orgs/<org>
+// or repos/<owner>/<repo> prefix they share. The fork pull request approval policy is not
+// available on every server, so it is left blank when it cannot be read.
+func getWorkflowPermissions(path string, settings *ActionsSettings, client *github.Client) error {
+
+	var workflow struct {
+		DefaultWorkflowPermissions   string `json:"default_workflow_permissions"`
+		CanApprovePullRequestReviews bool   `json:"can_approve_pull_request_reviews"`
+	}
+
+	req, err := client.NewRequest("GET", path+"/actions/permissions/workflow", nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(context.Background(), req, &workflow)
+	if err != nil {
+		return err
+	}
+	settings.DefaultWorkflowPermissions = workflow.DefaultWorkflowPermissions
+	settings.CanApprovePullRequestReviews = workflow.CanApprovePullRequestReviews
+
+	var forkApproval struct {
+		ApprovalPolicy string `json:"approval_policy"`
+	}
+
+	req, err = client.NewRequest("GET", path+"/actions/permissions/fork-pr-contributor-approval", nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(context.Background(), req, &forkApproval)
+	if err != nil && !isUnavailableError(err) {
+		return err
+	}
+	settings.ForkPRApproval = forkApproval.ApprovalPolicy
+
+	return nil
+}
+
+func getOrgActionsSettings(orgName string, client *github.Client) (*ActionsSettings, error) {
+
+	permissions, _, err := client.Organizations.GetActionsPermissions(context.Background(), orgName)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &ActionsSettings{
+		Enabled:        permissions.GetEnabledRepositories(),
+		AllowedActions: permissions.GetAllowedActions(),
+	}
+
+	if settings.AllowedActions == "selected" {
+		allowed, _, err := client.Organizations.GetActionsAllowed(context.Background(), orgName)
+		if err != nil {
+			return nil, err
+		}
+		settings.GithubOwnedAllowed = allowed.GetGithubOwnedAllowed()
+		settings.VerifiedAllowed = allowed.GetVerifiedAllowed()
+		settings.PatternsAllowed = allowed.PatternsAllowed
+	}
+
+	err = getWorkflowPermissions("orgs/"+orgName, settings, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func getRepoActionsSettings(orgName, repoName string, client *github.Client) (*ActionsSettings, error) {
+
+	permissions, _, err := client.Repositories.GetActionsPermissions(context.Background(), orgName, repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &ActionsSettings{
+		Enabled:        fmt.Sprintf("%t", permissions.GetEnabled()),
+		AllowedActions: permissions.GetAllowedActions(),
+	}
+
+	// a disabled repository has no further settings to read
+	if !permissions.GetEnabled() {
+		return settings, nil
+	}
+
+	if settings.AllowedActions == "selected" {
+		allowed, _, err := client.Repositories.GetActionsAllowed(context.Background(), orgName, repoName)
+		if err != nil {
+			return nil, err
+		}
+		settings.GithubOwnedAllowed = allowed.GetGithubOwnedAllowed()
+		settings.VerifiedAllowed = allowed.GetVerifiedAllowed()
+		settings.PatternsAllowed = allowed.PatternsAllowed
+	}
+
+	err = getWorkflowPermissions(fmt.Sprintf("repos/%s/%s", orgName, repoName), settings, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// WorkflowStatus is a workflow and the outcome of its most recent run.
+type WorkflowStatus struct {
+	Workflow   *github.Workflow
+	LastRunAt  time.Time
+	Status     string
+	Conclusion string
+}
+
+func getRepoWorkflows(orgName, repoName string, client *github.Client) ([]*WorkflowStatus, error) {
+
+	opts := &github.ListOptions{PerPage: 100}
+
+	allWorkflows := []*WorkflowStatus{}
+	for {
+		workflows, resp, err := client.Actions.ListWorkflows(context.Background(), orgName, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, workflow := range workflows.Workflows {
+			status := &WorkflowStatus{Workflow: workflow}
+
+			runs, _, err := client.Actions.ListWorkflowRunsByID(context.Background(), orgName, repoName, workflow.GetID(), &github.ListWorkflowRunsOptions{
+				ListOptions: github.ListOptions{PerPage: 1},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(runs.WorkflowRuns) > 0 {
+				run := runs.WorkflowRuns[0]
+				status.LastRunAt = run.GetCreatedAt().Time
+				status.Status = run.GetStatus()
+				status.Conclusion = run.GetConclusion()
+			}
+
+			allWorkflows = append(allWorkflows, status)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allWorkflows, nil
+}
+
+// runnerLabels joins the names of a runner's labels.
+func runnerLabels(runner *github.Runner) string {
+	labels := []string{}
+	for _, label := range runner.Labels {
+		labels = append(labels, label.GetName())
+	}
+	return strings.Join(labels, " ")
+}
+
+// getOrgRunnerGroups returns the runner groups of an organization along with the runners in each
+// group, keyed by group ID.
+func getOrgRunnerGroups(orgName string, client *github.Client) ([]*github.RunnerGroup, map[int64][]*github.Runner, error) {
+
+	opts := &github.ListOrgRunnerGroupOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	allGroups := []*github.RunnerGroup{}
+	for {
+		groups, resp, err := client.Actions.ListOrganizationRunnerGroups(context.Background(), orgName, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		allGroups = append(allGroups, groups.RunnerGroups...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	groupRunners := map[int64][]*github.Runner{}
+	for _, group := range allGroups {
+		runnerOpts := &github.ListOptions{PerPage: 100}
+		for {
+			runners, resp, err := client.Actions.ListRunnerGroupRunners(context.Background(), orgName, group.GetID(), runnerOpts)
+			if err != nil {
+				return nil, nil, err
+			}
+			groupRunners[group.GetID()] = append(groupRunners[group.GetID()], runners.Runners...)
+
+			if resp.NextPage == 0 {
+				break
+			}
+			runnerOpts.Page = resp.NextPage
+		}
+	}
+
+	return allGroups, groupRunners, nil
+}
+
+func getRepoRunners(orgName, repoName string, client *github.Client) ([]*github.Runner, error) {
+
+	opts := &github.ListOptions{PerPage: 100}
+
+	allRunners := []*github.Runner{}
+	for {
+		runners, resp, err := client.Actions.ListRunners(context.Background(), orgName, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+		allRunners = append(allRunners, runners.Runners...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allRunners, nil
+}
+
+// GenerateActionsReport inventories GitHub Actions across every organization and repository in
+// the enterprise. Workflows and their last run are written to actions-workflows.csv, self-hosted
+// runners to actions-runners.csv, runner groups to actions-runner-groups.csv and the Actions
+// policy of each organization and repository to actions-settings.csv.
+func GenerateActionsReport(enterpriseSlug string, client *githubv4.Client, restClient *github.Client) error {
+
+	writers := map[string]*csv.Writer{}
+	headers := map[string][]string{
+		"actions-workflows.csv":     {"owner", "repo", "workflow", "path", "state", "last_run_status", "last_run_conclusion", "last_run_at"},
+		"actions-runners.csv":       {"owner", "repo", "runner_group", "runner", "os", "status", "busy", "labels"},
+		"actions-runner-groups.csv": {"owner", "runner_group", "visibility", "default", "allows_public_repositories", "restricted_to_workflows", "runners"},
+		"actions-settings.csv":      append([]string{"owner", "repo"}, actionsSettingsColumns...),
+	}
+	for name, header := range headers {
+		file, err := os.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		writer := csv.NewWriter(file)
+		defer writer.Flush()
+
+		err = writer.Write(header)
+		if err != nil {
+			panic(err)
+		}
+		writers[name] = writer
+	}
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, org := range orgs {
+		orgName := string(org.Login)
+
+		start := time.Now()
+		log.Printf("Fetching Actions settings for the %s organization.", orgName)
+
+		orgSettings, err := getOrgActionsSettings(orgName, restClient)
+		if err != nil {
+			log.Printf("Unable to fetch Actions settings for %s: %v", orgName, err)
+			orgSettings = &ActionsSettings{}
+		}
+		err = writers["actions-settings.csv"].Write(append([]string{orgName, ""}, orgSettings.Values()...))
+		if err != nil {
+			panic(err)
+		}
+
+		groups, groupRunners, err := getOrgRunnerGroups(orgName, restClient)
+		if err != nil {
+			log.Printf("Unable to fetch runner groups for %s: %v", orgName, err)
+		}
+		for _, group := range groups {
+			err = writers["actions-runner-groups.csv"].Write([]string{
+				orgName,
+				group.GetName(),
+				group.GetVisibility(),
+				fmt.Sprintf("%t", group.GetDefault()),
+				fmt.Sprintf("%t", group.GetAllowsPublicRepositories()),
+				fmt.Sprintf("%t", group.GetRestrictedToWorkflows()),
+				fmt.Sprintf("%d", len(groupRunners[group.GetID()])),
+			})
+			if err != nil {
+				panic(err)
+			}
+
+			for _, runner := range groupRunners[group.GetID()] {
+				err = writers["actions-runners.csv"].Write([]string{
+					orgName,
+					"",
+					group.GetName(),
+					runner.GetName(),
+					runner.GetOS(),
+					runner.GetStatus(),
+					fmt.Sprintf("%t", runner.GetBusy()),
+					runnerLabels(runner),
+				})
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+
+		repos, err := getOrgRepos(orgName, false, client)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, repo := range repos {
+			if repo.IsArchived {
+				continue
+			}
+
+			repoSettings, err := getRepoActionsSettings(orgName, repo.Name, restClient)
+			if err != nil {
+				log.Printf("Unable to fetch Actions settings for %s/%s: %v", orgName, repo.Name, err)
+				continue
+			}
+			err = writers["actions-settings.csv"].Write(append([]string{orgName, repo.Name}, repoSettings.Values()...))
+			if err != nil {
+				panic(err)
+			}
+
+			if repoSettings.Enabled != "true" {
+				continue
+			}
+
+			workflows, err := getRepoWorkflows(orgName, repo.Name, restClient)
+			if err != nil {
+				log.Printf("Unable to fetch workflows for %s/%s: %v", orgName, repo.Name, err)
+			}
+			for _, workflow := range workflows {
+				err = writers["actions-workflows.csv"].Write([]string{
+					orgName,
+					repo.Name,
+					workflow.Workflow.GetName(),
+					workflow.Workflow.GetPath(),
+					workflow.Workflow.GetState(),
+					workflow.Status,
+					workflow.Conclusion,
+					formatTime(workflow.LastRunAt),
+				})
+				if err != nil {
+					panic(err)
+				}
+			}
+
+			runners, err := getRepoRunners(orgName, repo.Name, restClient)
+			if err != nil {
+				log.Printf("Unable to fetch runners for %s/%s: %v", orgName, repo.Name, err)
+			}
+			for _, runner := range runners {
+				err = writers["actions-runners.csv"].Write([]string{
+					orgName,
+					repo.Name,
+					"",
+					runner.GetName(),
+					runner.GetOS(),
+					runner.GetStatus(),
+					fmt.Sprintf("%t", runner.GetBusy()),
+					runnerLabels(runner),
+				})
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+
+		log.Printf("Fetched Actions inventory for %d repositories in %v", len(repos), time.Since(start))
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// AuditLogState remembers the last audit log event that was exported, so that the next run only
+// fetches newer events. Events sharing the last timestamp are tracked by document ID, since the
+// search phrase can only filter by time.
+type AuditLogState struct {
+	Scope           string   `json:"scope"`
+	LastTimestamp   int64    `json:"last_timestamp"`
+	LastDocumentIDs []string `json:"last_document_ids"`
+}
+
+// auditLogEvent holds the fields of an audit log event that are written to CSV. The full event is
+// kept as raw JSON.
+type auditLogEvent struct {
+	Timestamp  int64  `json:"@timestamp"`
+	DocumentID string `json:"_document_id"`
+	Action     string `json:"action"`
+	Actor      string `json:"actor"`
+	Org        string `json:"org"`
+	Repo       string `json:"repo"`
+	User       string `json:"user"`
+}
+
+func loadAuditLogState(path, scope string) (*AuditLogState, error) {
+
+	state := &AuditLogState{Scope: scope}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, err
+	}
+	if state.Scope != scope {
+		return nil, fmt.Errorf("state file %s belongs to %s, not %s", path, state.Scope, scope)
+	}
+
+	return state, nil
+}
+
+func saveAuditLogState(path string, state *AuditLogState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// parseAuditLogTime accepts a date or an RFC 3339 timestamp.
+func parseAuditLogTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// ExportAuditLog streams the audit log of an enterprise, or of an organization when orgName is
+// set, to output as NDJSON or CSV. phrase is an audit log search phrase such as
+// "action:repo.destroy", and since and until limit the time window when set. When statePath is
+// set the last exported event is remembered there, the next run only fetches newer events and
+// they are appended to output, so the audit log can be archived past GitHub's retention window.
+func ExportAuditLog(enterpriseSlug, orgName, phrase, since, until, format, output, statePath string, client *github.Client) error {
+
+	path := fmt.Sprintf("enterprises/%s/audit-log", enterpriseSlug)
+	scope := "enterprise/" + enterpriseSlug
+	if orgName != "" {
+		path = fmt.Sprintf("orgs/%s/audit-log", orgName)
+		scope = "org/" + orgName
+	}
+
+	state := &AuditLogState{Scope: scope}
+	if statePath != "" {
+		var err error
+		state, err = loadAuditLogState(statePath, scope)
+		if err != nil {
+			return err
+		}
+	}
+
+	if since != "" {
+		t, err := parseAuditLogTime(since)
+		if err != nil {
+			return err
+		}
+		phrase += " created:>=" + t.UTC().Format(time.RFC3339)
+	}
+	if until != "" {
+		t, err := parseAuditLogTime(until)
+		if err != nil {
+			return err
+		}
+		phrase += " created:<=" + t.UTC().Format(time.RFC3339)
+	}
+	// the state file takes over from since once an event has been exported
+	if state.LastTimestamp > 0 {
+		phrase += " created:>=" + time.UnixMilli(state.LastTimestamp).UTC().Format(time.RFC3339)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if statePath != "" {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(output, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	var writer *csv.Writer
+	if format == "csv" {
+		writer = csv.NewWriter(file)
+		defer writer.Flush()
+
+		if info.Size() == 0 {
+			err = writer.Write([]string{"timestamp", "document_id", "action", "actor", "org", "repo", "user", "event"})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, id := range state.LastDocumentIDs {
+		seen[id] = true
+	}
+
+	params := url.Values{}
+	params.Set("phrase", strings.TrimSpace(phrase))
+	params.Set("include", "all")
+	params.Set("order", "asc")
+	params.Set("per_page", "100")
+
+	count := 0
+	start := time.Now()
+	log.Printf("Fetching audit log events for %s with phrase %q.", scope, params.Get("phrase"))
+	for {
+		req, err := client.NewRequest("GET", path+"?"+params.Encode(), nil)
+		if err != nil {
+			return err
+		}
+
+		var events []json.RawMessage
+		resp, err := client.Do(context.Background(), req, &events)
+		if err != nil {
+			return err
+		}
+
+		for _, raw := range events {
+			var event auditLogEvent
+			err = json.Unmarshal(raw, &event)
+			if err != nil {
+				return err
+			}
+
+			// the search phrase has second precision, so events already exported are skipped here
+			if event.Timestamp < state.LastTimestamp || (event.Timestamp == state.LastTimestamp && seen[event.DocumentID]) {
+				continue
+			}
+
+			if writer != nil {
+				err = writer.Write([]string{
+					time.UnixMilli(event.Timestamp).UTC().Format(time.RFC3339),
+					event.DocumentID,
+					event.Action,
+					event.Actor,
+					event.Org,
+					event.Repo,
+					event.User,
+					string(raw),
+				})
+			} else {
+				_, err = fmt.Fprintf(file, "%s\n", raw)
+			}
+			if err != nil {
+				return err
+			}
+			count++
+
+			if event.Timestamp > state.LastTimestamp {
+				state.LastTimestamp = event.Timestamp
+				state.LastDocumentIDs = nil
+				seen = map[string]bool{}
+			}
+			state.LastDocumentIDs = append(state.LastDocumentIDs, event.DocumentID)
+			seen[event.DocumentID] = true
+		}
+
+		// save progress after every page so an interrupted export resumes where it stopped
+		if statePath != "" {
+			if writer != nil {
+				writer.Flush()
+			}
+			err = saveAuditLogState(statePath, state)
+			if err != nil {
+				return err
+			}
+		}
+
+		if resp.After == "" {
+			break
+		}
+		params.Set("after", resp.After)
+	}
+
+	log.Printf("Exported %d audit log events in %v", count, time.Since(start))
+	log.Printf("Wrote %d records to %s", count, output)
+
+	return nil
+}
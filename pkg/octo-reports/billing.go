@@ -0,0 +1,256 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+// List prices in USD used to project spend.
+const (
+	paidBandwidthPricePerGB = 0.50
+	paidStoragePricePerGB   = 0.25
+)
+
+// minutePricesPerCore are the per-minute list prices of a hosted runner per core. macOS runners
+// are priced per runner rather than per core.
+var minutePricesPerCore = map[string]float64{
+	"UBUNTU":  0.004,
+	"WINDOWS": 0.008,
+}
+
+var macOSMinutePrice = 0.08
+
+// coreCount matches the core count in larger runner SKUs such as ubuntu_4_core.
+var coreCount = regexp.MustCompile(`_(\d+)_core`)
+
+// minutePrice returns the per-minute list price of an Actions minutes breakdown key, which is
+// either an OS such as UBUNTU or a runner SKU such as windows_8_core. Standard runners have two
+// cores.
+func minutePrice(sku string) float64 {
+	upper := strings.ToUpper(sku)
+	if strings.HasPrefix(upper, "MACOS") {
+		return macOSMinutePrice
+	}
+
+	cores := 2
+	if match := coreCount.FindStringSubmatch(sku); match != nil {
+		cores, _ = strconv.Atoi(match[1])
+	}
+
+	for prefix, price := range minutePricesPerCore {
+		if strings.HasPrefix(upper, prefix) {
+			return price * float64(cores)
+		}
+	}
+	return 0
+}
+
+// BillingUsage is the Actions, Packages and shared storage usage of an enterprise or organization
+// for the current billing cycle.
+type BillingUsage struct {
+	Scope    string
+	Actions  *github.ActionBilling
+	Packages *github.PackageBilling
+	Storage  *github.StorageBilling
+}
+
+// projection returns the factor that scales usage so far to the end of the billing cycle. The
+// API only reports the days left in the cycle, so the cycle is taken to be the month that ends
+// then, which holds whichever day of the month the cycle starts on.
+func (u *BillingUsage) projection(now time.Time) float64 {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	end := today.AddDate(0, 0, u.Storage.DaysLeftInBillingCycle)
+	start := end.AddDate(0, -1, 0)
+
+	elapsed := today.Sub(start).Hours() / 24
+	if elapsed <= 0 {
+		return 1
+	}
+	return end.Sub(start).Hours() / 24 / elapsed
+}
+
+// overage returns the projected usage beyond what is included in the plan.
+func overage(projected, included float64) float64 {
+	if projected < included {
+		return 0
+	}
+	return projected - included
+}
+
+// blendedMinutePrice weights the price of each runner type by the minutes used on it.
+func (u *BillingUsage) blendedMinutePrice() float64 {
+	var minutes, cost float64
+	for sku, used := range u.Actions.MinutesUsedBreakdown {
+		minutes += float64(used)
+		cost += float64(used) * minutePrice(sku)
+	}
+	if minutes == 0 {
+		return 0
+	}
+	return cost / minutes
+}
+
+// getBillingUsage fetches the billing usage under path, which is orgs/<org> or
+// enterprises/<enterprise>. The organization endpoints are in go-github but the enterprise ones
+// are not, and both return the same shapes.
+func getBillingUsage(scope, path string, client *github.Client) (*BillingUsage, error) {
+
+	usage := &BillingUsage{
+		Scope:    scope,
+		Actions:  &github.ActionBilling{},
+		Packages: &github.PackageBilling{},
+		Storage:  &github.StorageBilling{},
+	}
+
+	targets := map[string]interface{}{
+		"actions":        usage.Actions,
+		"packages":       usage.Packages,
+		"shared-storage": usage.Storage,
+	}
+	for endpoint, target := range targets {
+		req, err := client.NewRequest("GET", path+"/settings/billing/"+endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		_, err = client.Do(context.Background(), req, target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return usage, nil
+}
+
+// GenerateBillingReport writes the Actions minutes, Packages bandwidth and shared storage usage
+// of the enterprise and each of its organizations to billing.csv, with the spend projected to the
+// end of the billing cycle from the run rate so far. Actions minutes by runner type are written to
+// billing-actions-minutes.csv. Spend is estimated from list prices.
+func GenerateBillingReport(enterpriseSlug string, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("billing.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"scope", "days_left_in_cycle",
+		"actions_minutes_used", "actions_paid_minutes_used", "actions_included_minutes", "actions_projected_minutes", "actions_projected_spend",
+		"packages_bandwidth_gb", "packages_paid_bandwidth_gb", "packages_included_bandwidth_gb", "packages_projected_bandwidth_gb", "packages_projected_spend",
+		"storage_estimated_gb", "storage_estimated_paid_gb", "storage_projected_spend",
+		"projected_spend",
+	}
+	err = writer.Write(header)
+	if err != nil {
+		panic(err)
+	}
+
+	minutesFile, err := os.Create("billing-actions-minutes.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer minutesFile.Close()
+
+	minutesWriter := csv.NewWriter(minutesFile)
+	defer minutesWriter.Flush()
+
+	err = minutesWriter.Write([]string{"scope", "runner", "minutes_used", "projected_minutes", "price_per_minute"})
+	if err != nil {
+		panic(err)
+	}
+
+	usages := []*BillingUsage{}
+
+	usage, err := getBillingUsage("enterprise/"+enterpriseSlug, "enterprises/"+enterpriseSlug, restClient)
+	if err != nil {
+		log.Printf("Unable to fetch billing usage for the %s enterprise: %v", enterpriseSlug, err)
+	} else {
+		usages = append(usages, usage)
+	}
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+	for _, org := range orgs {
+		usage, err := getBillingUsage(string(org.Login), "orgs/"+string(org.Login), restClient)
+		if err != nil {
+			log.Printf("Unable to fetch billing usage for %s: %v", org.Login, err)
+			continue
+		}
+		usages = append(usages, usage)
+	}
+
+	now := time.Now()
+	for _, usage := range usages {
+		factor := usage.projection(now)
+
+		// usage is projected before the included amounts are taken off, so that an account still
+		// within its quota is charged for the overage its run rate will reach
+		projectedMinutes := usage.Actions.TotalMinutesUsed * factor
+		actionsSpend := overage(projectedMinutes, usage.Actions.IncludedMinutes) * usage.blendedMinutePrice()
+		projectedBandwidth := float64(usage.Packages.TotalGigabytesBandwidthUsed) * factor
+		packagesSpend := overage(projectedBandwidth, usage.Packages.IncludedGigabytesBandwidth) * paidBandwidthPricePerGB
+		// the storage estimate already covers the whole month
+		storageSpend := usage.Storage.EstimatedPaidStorageForMonth * paidStoragePricePerGB
+
+		record := []string{
+			usage.Scope,
+			fmt.Sprintf("%d", usage.Storage.DaysLeftInBillingCycle),
+			fmt.Sprintf("%.0f", usage.Actions.TotalMinutesUsed),
+			fmt.Sprintf("%.0f", usage.Actions.TotalPaidMinutesUsed),
+			fmt.Sprintf("%.0f", usage.Actions.IncludedMinutes),
+			fmt.Sprintf("%.0f", projectedMinutes),
+			fmt.Sprintf("%.2f", actionsSpend),
+			fmt.Sprintf("%d", usage.Packages.TotalGigabytesBandwidthUsed),
+			fmt.Sprintf("%d", usage.Packages.TotalPaidGigabytesBandwidthUsed),
+			fmt.Sprintf("%.0f", usage.Packages.IncludedGigabytesBandwidth),
+			fmt.Sprintf("%.0f", projectedBandwidth),
+			fmt.Sprintf("%.2f", packagesSpend),
+			fmt.Sprintf("%.2f", usage.Storage.EstimatedStorageForMonth),
+			fmt.Sprintf("%.2f", usage.Storage.EstimatedPaidStorageForMonth),
+			fmt.Sprintf("%.2f", storageSpend),
+			fmt.Sprintf("%.2f", actionsSpend+packagesSpend+storageSpend),
+		}
+		err = writer.Write(record)
+		if err != nil {
+			panic(err)
+		}
+
+		runners := []string{}
+		for runner := range usage.Actions.MinutesUsedBreakdown {
+			runners = append(runners, runner)
+		}
+		sort.Strings(runners)
+		for _, runner := range runners {
+			minutes := usage.Actions.MinutesUsedBreakdown[runner]
+			err = minutesWriter.Write([]string{
+				usage.Scope,
+				runner,
+				fmt.Sprintf("%d", minutes),
+				fmt.Sprintf("%.0f", float64(minutes)*factor),
+				fmt.Sprintf("%.3f", minutePrice(runner)),
+			})
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	log.Printf("Wrote %d records to billing.csv", len(usages))
+
+	return nil
+}
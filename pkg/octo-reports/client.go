@@ -3,6 +3,7 @@ package octoreports
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/google/go-github/v50/github"
 	"github.com/shurcooL/githubv4"
@@ -48,12 +49,37 @@ func NewV4Client(url, token string) *githubv4.Client {
 	return client
 }
 
-func NewV3Client(token string) *github.Client {
+func NewV3Client(url, token string) *github.Client {
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+
+	ctx := context.Background()
+
+	httpClient := &http.Client{
+		Transport: transport,
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+
 	src := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
-	httpClient := oauth2.NewClient(context.Background(), src)
+
+	httpClient = oauth2.NewClient(ctx, src)
+
+	if url != "https://api.github.com/graphql" {
+		// the config holds the GraphQL endpoint, e.g. https://github.mycompany.com/api/graphql
+		baseURL := strings.TrimSuffix(strings.TrimSuffix(url, "graphql"), "api/")
+		client, err := github.NewEnterpriseClient(baseURL, baseURL, httpClient)
+		if err != nil {
+			panic(err)
+		}
+		return client
+	}
 
 	client := github.NewClient(httpClient)
+
 	return client
 }
@@ -0,0 +1,274 @@
+package octoreports
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+type CodeownersBlob struct {
+	Blob struct {
+		Oid  string
+		Text string
+	} `graphql:"... on Blob"`
+}
+
+// CodeownersRule is a single pattern in a CODEOWNERS file and the owners assigned to it.
+type CodeownersRule struct {
+	Line    int
+	Pattern string
+	Owners  []string
+}
+
+// getRepoCodeowners fetches the CODEOWNERS file of a repository from the locations GitHub looks
+// in, in the same order of precedence. An empty path means the repository has no CODEOWNERS file,
+// while an empty text with a path means the file exists but is empty.
+func getRepoCodeowners(orgName, repoName string, client *githubv4.Client) (string, string, error) {
+
+	variables := map[string]interface{}{
+		"orgName":  githubv4.String(orgName),
+		"repoName": githubv4.String(repoName),
+	}
+
+	var query struct {
+		Repository struct {
+			GitHub CodeownersBlob `graphql:"github: object(expression: \"HEAD:.github/CODEOWNERS\")"`
+			Root   CodeownersBlob `graphql:"root: object(expression: \"HEAD:CODEOWNERS\")"`
+			Docs   CodeownersBlob `graphql:"docs: object(expression: \"HEAD:docs/CODEOWNERS\")"`
+		} `graphql:"repository(owner: $orgName, name: $repoName)"`
+		RateLimit RateLimit
+	}
+
+	err := client.Query(context.Background(), &query, variables)
+	if err != nil {
+		return "", "", err
+	}
+
+	// check rate limit
+	if query.RateLimit.Remaining < 100 {
+		log.Printf("Rate limit: %d/%d, resets at: %s", query.RateLimit.Remaining, query.RateLimit.Limit, query.RateLimit.ResetAt)
+		time.Sleep(time.Until(query.RateLimit.ResetAt.Time))
+	}
+
+	locations := []struct {
+		path string
+		blob CodeownersBlob
+	}{
+		{".github/CODEOWNERS", query.Repository.GitHub},
+		{"CODEOWNERS", query.Repository.Root},
+		{"docs/CODEOWNERS", query.Repository.Docs},
+	}
+	for _, location := range locations {
+		// the object ID is only set when the file exists, which tells an empty file from a missing one
+		if location.blob.Blob.Oid != "" {
+			return location.path, location.blob.Blob.Text, nil
+		}
+	}
+
+	return "", "", nil
+}
+
+// parseCodeowners splits a CODEOWNERS file into its rules, dropping blank lines and comments.
+func parseCodeowners(text string) []CodeownersRule {
+
+	rules := []CodeownersRule{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		for i, field := range fields {
+			if strings.HasPrefix(field, "#") {
+				fields = fields[:i]
+				break
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		rules = append(rules, CodeownersRule{
+			Line:    line,
+			Pattern: fields[0],
+			Owners:  fields[1:],
+		})
+	}
+
+	return rules
+}
+
+// GenerateCodeownersReport checks the CODEOWNERS file of every repository in the enterprise.
+// Team owners are validated against the teams of the repository's organization and user owners
+// against its members and collaborators, and every owner must have write access to be able to
+// approve reviews. Email owners cannot be resolved to an account and are listed as unverified.
+func GenerateCodeownersReport(enterpriseSlug string, client *githubv4.Client) error {
+	file, err := os.Create("codeowners.csv")
+	if err != nil {
+		fmt.Println("Error creating the CSV file:", err)
+	}
+
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"owner", "name", "codeowners_path", "rules", "owners", "invalid_owners", "owners_without_write", "unverified_owners", "issues"}
+
+	err = writer.Write(header)
+	if err != nil {
+		fmt.Println("Error writing the header row:", err)
+		return nil
+	}
+
+	orgs, _ := getEnterpriseOrgs(enterpriseSlug, client)
+	for _, org := range orgs {
+		orgName := string(org.Login)
+
+		repos, err := getOrgRepos(orgName, false, client)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		teams, err := getOrgTeams(orgName, client)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		teamAccess, err := getOrgTeamAccess(orgName, teams, client)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		members, err := getOrgMembersWithRole(orgName, client)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		isMember := map[string]bool{}
+		for _, member := range members {
+			isMember[strings.ToLower(member.Login)] = true
+		}
+
+		// team permissions per repository, keyed by lower case slug
+		teamPermissions := map[string]map[string]string{}
+		for _, team := range teams {
+			permissions := map[string]string{}
+			for _, grant := range teamAccess[team.Slug] {
+				permissions[grant.Repo] = grant.Permission
+			}
+			teamPermissions[strings.ToLower(team.Slug)] = permissions
+		}
+
+		count := 0
+		for _, repo := range repos {
+			if repo.IsArchived {
+				continue
+			}
+
+			path, text, err := getRepoCodeowners(orgName, repo.Name, client)
+			if err != nil {
+				log.Printf("Unable to fetch CODEOWNERS for %s/%s: %v", orgName, repo.Name, err)
+				continue
+			}
+
+			rules := parseCodeowners(text)
+
+			owners := []string{}
+			for _, rule := range rules {
+				for _, owner := range rule.Owners {
+					if !contains(owners, owner) {
+						owners = append(owners, owner)
+					}
+				}
+			}
+
+			// collaborators are only needed to resolve user owners
+			var userPermissions map[string]string
+			for _, owner := range owners {
+				if !strings.HasPrefix(owner, "@") || strings.Contains(owner, "/") {
+					continue
+				}
+				collaborators, err := getRepoCollaborators(orgName, repo.Name, githubv4.CollaboratorAffiliationAll, client)
+				if err != nil {
+					log.Fatal(err)
+				}
+				userPermissions = map[string]string{}
+				for _, collaborator := range collaborators {
+					userPermissions[strings.ToLower(collaborator.Login)] = collaborator.Permission
+				}
+				break
+			}
+
+			invalid := []string{}
+			withoutWrite := []string{}
+			unverified := []string{}
+			for _, owner := range owners {
+				switch {
+				case strings.HasPrefix(owner, "@") && strings.Contains(owner, "/"):
+					ownerOrg, slug, _ := strings.Cut(strings.TrimPrefix(owner, "@"), "/")
+					permissions, ok := teamPermissions[strings.ToLower(slug)]
+					if !strings.EqualFold(ownerOrg, orgName) || !ok {
+						invalid = append(invalid, owner)
+					} else if permissionRank(permissions[repo.Name]) < permissionRank("WRITE") {
+						withoutWrite = append(withoutWrite, owner)
+					}
+				case strings.HasPrefix(owner, "@"):
+					login := strings.ToLower(strings.TrimPrefix(owner, "@"))
+					permission, ok := userPermissions[login]
+					if !ok && !isMember[login] {
+						invalid = append(invalid, owner)
+					} else if permissionRank(permission) < permissionRank("WRITE") {
+						withoutWrite = append(withoutWrite, owner)
+					}
+				case strings.Contains(owner, "@"):
+					unverified = append(unverified, owner)
+				default:
+					invalid = append(invalid, owner)
+				}
+			}
+
+			issues := []string{}
+			switch {
+			case path == "":
+				issues = append(issues, "missing")
+			case strings.TrimSpace(text) == "":
+				issues = append(issues, "empty")
+			case len(owners) == 0:
+				issues = append(issues, "no_owners")
+			}
+			if len(invalid) > 0 {
+				issues = append(issues, "invalid_owners")
+			}
+			if len(withoutWrite) > 0 {
+				issues = append(issues, "owners_without_write")
+			}
+
+			record := []string{
+				orgName,
+				repo.Name,
+				path,
+				fmt.Sprintf("%d", len(rules)),
+				strings.Join(owners, " "),
+				strings.Join(invalid, " "),
+				strings.Join(withoutWrite, " "),
+				strings.Join(unverified, " "),
+				strings.Join(issues, ", "),
+			}
+
+			err = writer.Write(record)
+			if err != nil {
+				fmt.Println("Error writing the record:", err)
+			}
+			count++
+		}
+
+		log.Printf("Wrote %d records to codeowners.csv", count)
+	}
+
+	return nil
+}
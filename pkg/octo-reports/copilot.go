@@ -0,0 +1,249 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+// copilotSeatPrices are the monthly list prices in USD of a Copilot seat by plan.
+var copilotSeatPrices = map[string]float64{
+	"business":   19,
+	"enterprise": 39,
+}
+
+// CopilotSeat is a Copilot seat assigned in an organization. go-github does not model the
+// Copilot API, so the response is decoded here.
+type CopilotSeat struct {
+	CreatedAt               time.Time  `json:"created_at"`
+	PendingCancellationDate string     `json:"pending_cancellation_date"`
+	LastActivityAt          *time.Time `json:"last_activity_at"`
+	LastActivityEditor      string     `json:"last_activity_editor"`
+	PlanType                string     `json:"plan_type"`
+	Assignee                struct {
+		Login string `json:"login"`
+	} `json:"assignee"`
+	AssigningTeam struct {
+		Slug string `json:"slug"`
+	} `json:"assigning_team"`
+}
+
+// getOrgCopilotPlan returns the Copilot plan of an organization, which prices the seats that do
+// not report their own plan.
+func getOrgCopilotPlan(orgName string, client *github.Client) (string, error) {
+
+	var billing struct {
+		PlanType string `json:"plan_type"`
+	}
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("orgs/%s/copilot/billing", orgName), nil)
+	if err != nil {
+		return "", err
+	}
+	_, err = client.Do(context.Background(), req, &billing)
+	if err != nil {
+		return "", err
+	}
+
+	return billing.PlanType, nil
+}
+
+func getOrgCopilotSeats(orgName string, client *github.Client) ([]*CopilotSeat, error) {
+
+	allSeats := []*CopilotSeat{}
+	page := 1
+	for {
+		req, err := client.NewRequest("GET", fmt.Sprintf("orgs/%s/copilot/billing/seats?per_page=100&page=%d", orgName, page), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var seats struct {
+			Seats []*CopilotSeat `json:"seats"`
+		}
+		resp, err := client.Do(context.Background(), req, &seats)
+		if err != nil {
+			return nil, err
+		}
+		allSeats = append(allSeats, seats.Seats...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		page = resp.NextPage
+	}
+
+	return allSeats, nil
+}
+
+// GenerateCopilotReport lists the Copilot seats of every organization in the enterprise in
+// copilot-seats.csv, flagging seats with no activity in the last inactiveDays days. The seat count
+// and monthly cost of each organization, including the cost of its idle seats, are written to
+// copilot-summary.csv for chargeback.
+func GenerateCopilotReport(enterpriseSlug string, inactiveDays int, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("copilot-seats.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"org", "login", "name", "enterprise_member", "plan", "assigning_team", "assigned_at", "last_activity_at", "last_activity_editor", "pending_cancellation_date", "days_inactive", "inactive"}
+	err = writer.Write(header)
+	if err != nil {
+		panic(err)
+	}
+
+	summaryFile, err := os.Create("copilot-summary.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer summaryFile.Close()
+
+	summaryWriter := csv.NewWriter(summaryFile)
+	defer summaryWriter.Flush()
+
+	err = summaryWriter.Write([]string{"org", "plan", "seats", "active_seats", "inactive_seats", "pending_cancellation", "monthly_cost", "inactive_monthly_cost"})
+	if err != nil {
+		panic(err)
+	}
+
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -inactiveDays)
+
+	members, err := getEnterpriseMembers(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+	names := map[string]string{}
+	for _, member := range members {
+		names[member.Login] = member.Name
+	}
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	total := 0
+	// a user with seats in several organizations is billed once, at the highest seat price
+	userCosts := map[string]float64{}
+	for _, org := range orgs {
+		orgName := string(org.Login)
+
+		plan, err := getOrgCopilotPlan(orgName, restClient)
+		if err != nil {
+			// organizations without Copilot return an error here
+			log.Printf("Skipping %s, unable to fetch its Copilot plan: %v", orgName, err)
+			continue
+		}
+
+		seats, err := getOrgCopilotSeats(orgName, restClient)
+		if err != nil {
+			log.Printf("Unable to fetch Copilot seats for %s: %v", orgName, err)
+			continue
+		}
+
+		inactive := 0
+		pending := 0
+		fallbacks := 0
+		uncosted := 0
+		var cost, inactiveCost float64
+		for _, seat := range seats {
+			seatPlan := seat.PlanType
+			if seatPlan == "" || seatPlan == "unknown" {
+				seatPlan = plan
+				fallbacks++
+			}
+			if _, ok := copilotSeatPrices[seatPlan]; !ok {
+				uncosted++
+			}
+
+			lastActivity := time.Time{}
+			if seat.LastActivityAt != nil {
+				lastActivity = *seat.LastActivityAt
+			}
+
+			// seats assigned within the window have not had the chance to be used yet
+			isInactive := lastActivity.Before(cutoff) && seat.CreatedAt.Before(cutoff)
+			daysInactive := "never"
+			if !lastActivity.IsZero() {
+				daysInactive = fmt.Sprintf("%d", int(now.Sub(lastActivity).Hours()/24))
+			}
+
+			cost += copilotSeatPrices[seatPlan]
+			if isInactive {
+				inactive++
+				inactiveCost += copilotSeatPrices[seatPlan]
+			}
+			if seat.PendingCancellationDate != "" {
+				pending++
+			}
+			if copilotSeatPrices[seatPlan] > userCosts[seat.Assignee.Login] {
+				userCosts[seat.Assignee.Login] = copilotSeatPrices[seatPlan]
+			}
+
+			name, isMember := names[seat.Assignee.Login]
+
+			record := []string{
+				orgName,
+				seat.Assignee.Login,
+				name,
+				fmt.Sprintf("%t", isMember),
+				seatPlan,
+				seat.AssigningTeam.Slug,
+				formatTime(seat.CreatedAt),
+				formatTime(lastActivity),
+				seat.LastActivityEditor,
+				seat.PendingCancellationDate,
+				daysInactive,
+				fmt.Sprintf("%t", isInactive),
+			}
+			err = writer.Write(record)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		err = summaryWriter.Write([]string{
+			orgName,
+			plan,
+			fmt.Sprintf("%d", len(seats)),
+			fmt.Sprintf("%d", len(seats)-inactive),
+			fmt.Sprintf("%d", inactive),
+			fmt.Sprintf("%d", pending),
+			fmt.Sprintf("%.2f", cost),
+			fmt.Sprintf("%.2f", inactiveCost),
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		if fallbacks > 0 {
+			log.Printf("%d Copilot seats in %s do not report a plan, priced at the organization's %s plan", fallbacks, orgName, plan)
+		}
+		if uncosted > 0 {
+			log.Printf("%d Copilot seats in %s have no known plan price and are not costed", uncosted, orgName)
+		}
+
+		total += len(seats)
+		log.Printf("Found %d Copilot seats in %s, %d inactive", len(seats), orgName, inactive)
+	}
+
+	var totalCost float64
+	for _, cost := range userCosts {
+		totalCost += cost
+	}
+	log.Printf("Found %d Copilot seats held by %d users costing %.2f per month", total, len(userCosts), totalCost)
+	log.Printf("Wrote %d records to copilot-seats.csv", total)
+
+	return nil
+}
@@ -3,10 +3,16 @@ package octoreports
 import (
 	"context"
 	"encoding/csv"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/google/go-github/v50/github"
 	"github.com/shurcooL/githubv4"
 )
 
@@ -107,6 +113,16 @@ func getEnterpriseMembers(enterpriseSlug string, client *githubv4.Client) ([]*Me
 		}
 
 		for _, member := range query.Enterprise.Members.Nodes {
+			// members that are not enterprise managed users are returned as a User
+			if member.EnterpriseUserAccount.Login == "" {
+				allMembers = append(allMembers, &Member{
+					Login: member.User.Login,
+					Name:  member.User.Name,
+					Id:    member.User.Id,
+				})
+				continue
+			}
+
 			allMembers = append(allMembers, &Member{
 				Login: member.EnterpriseUserAccount.Login,
 				Name:  member.EnterpriseUserAccount.Name,
@@ -153,3 +169,351 @@ func GenerateEnterpriseMembershipReport(enterpriseSlug string, client *githubv4.
 
 	return nil
 }
+
+type BillingInfo struct {
+	TotalLicenses           int
+	TotalAvailableLicenses  int
+	AllLicensableUsersCount int
+}
+
+func getEnterpriseBillingInfo(enterpriseSlug string, client *githubv4.Client) (*BillingInfo, error) {
+
+	variables := map[string]interface{}{
+		"enterpriseSlug": githubv4.String(enterpriseSlug),
+	}
+
+	var query struct {
+		Enterprise struct {
+			BillingInfo BillingInfo
+		} `graphql:"enterprise(slug: $enterpriseSlug)"`
+	}
+
+	log.Printf("Fetching billing info for the %s Enterprise.", enterpriseSlug)
+	err := client.Query(context.Background(), &query, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	return &query.Enterprise.BillingInfo, nil
+}
+
+func getEnterpriseOutsideCollaborators(enterpriseSlug string, client *githubv4.Client) ([]*Member, error) {
+
+	variables := map[string]interface{}{
+		"enterpriseSlug": githubv4.String(enterpriseSlug),
+		"cursor":         (*githubv4.String)(nil),
+	}
+
+	// only outside collaborators on private repositories occupy a seat
+	var query struct {
+		Enterprise struct {
+			OwnerInfo struct {
+				OutsideCollaborators struct {
+					PageInfo struct {
+						EndCursor   githubv4.String
+						HasNextPage bool
+					}
+					Nodes []struct {
+						Id    string
+						Login string
+						Name  string
+					}
+				} `graphql:"outsideCollaborators(first: 100, after: $cursor, visibility: PRIVATE)"`
+			}
+		} `graphql:"enterprise(slug: $enterpriseSlug)"`
+		RateLimit RateLimit
+	}
+
+	allCollaborators := []*Member{}
+	start := time.Now()
+	log.Printf("Fetching all outside collaborators for the %s Enterprise.", enterpriseSlug)
+	for {
+		err := client.Query(context.Background(), &query, variables)
+		if err != nil {
+			panic(err)
+		}
+
+		// check rate limit
+		if query.RateLimit.Remaining < 100 {
+			log.Printf("Rate limit: %d/%d, resets at: %s", query.RateLimit.Remaining, query.RateLimit.Limit, query.RateLimit.ResetAt)
+			time.Sleep(time.Until(query.RateLimit.ResetAt.Time))
+		}
+
+		for _, collaborator := range query.Enterprise.OwnerInfo.OutsideCollaborators.Nodes {
+			allCollaborators = append(allCollaborators, &Member{
+				Login: collaborator.Login,
+				Name:  collaborator.Name,
+				Id:    collaborator.Id,
+			})
+		}
+
+		if !query.Enterprise.OwnerInfo.OutsideCollaborators.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(query.Enterprise.OwnerInfo.OutsideCollaborators.PageInfo.EndCursor)
+	}
+
+	log.Printf("Found %d outside collaborators in the %s Enterprise", len(allCollaborators), enterpriseSlug)
+	log.Printf("Fetched all outside collaborators in %s", time.Since(start))
+
+	return allCollaborators, nil
+}
+
+// isManagedEnterprise reports whether the enterprise uses Enterprise Managed Users. The
+// enterprise SCIM endpoint only exists for managed enterprises, so a 404 means it does not. known
+// is false when the setting could not be read, such as when the token lacks the scim:enterprise
+// scope.
+func isManagedEnterprise(enterpriseSlug string, client *github.Client) (managed bool, known bool) {
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("scim/v2/enterprises/%s/Users?count=1", enterpriseSlug), nil)
+	if err != nil {
+		return false, false
+	}
+	_, err = client.Do(context.Background(), req, nil)
+	if err == nil {
+		return true, true
+	}
+
+	var errorResponse *github.ErrorResponse
+	if errors.As(err, &errorResponse) && errorResponse.Response.StatusCode == http.StatusNotFound {
+		return false, true
+	}
+	log.Printf("Unable to tell whether %s uses managed users, guessing from logins: %v", enterpriseSlug, err)
+
+	return false, false
+}
+
+// memberType classifies an enterprise member. Every member of a managed enterprise is a managed
+// user. When the enterprise's setting is not known, logins with an underscore are guessed to be
+// managed users, since those carry the enterprise shortcode after an underscore, but personal
+// accounts can contain one too.
+func memberType(login string, managed, known bool) string {
+	if known {
+		if managed {
+			return "emu"
+		}
+		return "standard"
+	}
+	if strings.Contains(login, "_") {
+		return "emu"
+	}
+	return "standard"
+}
+
+// getOrgOutsideCollaboratorLogins returns the logins of the outside collaborators with access to
+// any repository in an organization.
+func getOrgOutsideCollaboratorLogins(orgName string, client *github.Client) ([]string, error) {
+
+	opts := &github.ListOutsideCollaboratorsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	logins := []string{}
+	for {
+		users, resp, err := client.Organizations.ListOutsideCollaborators(context.Background(), orgName, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			logins = append(logins, user.GetLogin())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	return logins, nil
+}
+
+func GenerateLicenseReport(enterpriseSlug string, client *githubv4.Client, restClient *github.Client) error {
+
+	billingInfo, err := getEnterpriseBillingInfo(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	allMembers, err := getEnterpriseMembers(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	outsideCollaborators, err := getEnterpriseOutsideCollaborators(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	managed, known := isManagedEnterprise(enterpriseSlug, restClient)
+
+	// seat holders keyed by login, with the orgs each one belongs to
+	seats := map[string]*Member{}
+	seatTypes := map[string]string{}
+	seatOrgs := map[string][]string{}
+	for _, member := range allMembers {
+		seats[member.Login] = member
+		seatTypes[member.Login] = memberType(member.Login, managed, known)
+	}
+	for _, collaborator := range outsideCollaborators {
+		if _, ok := seats[collaborator.Login]; ok {
+			continue
+		}
+		seats[collaborator.Login] = collaborator
+		seatTypes[collaborator.Login] = "outside_collaborator"
+	}
+
+	orgSeats := map[string]int{}
+	for _, org := range orgs {
+		orgMembers, err := getOrgMembersWithRole(string(org.Login), client)
+		if err != nil {
+			panic(err)
+		}
+		orgSeats[string(org.Login)] = len(orgMembers)
+		for _, member := range orgMembers {
+			seatOrgs[member.Login] = append(seatOrgs[member.Login], string(org.Login))
+		}
+
+		// outside collaborators are not members, so their orgs are the ones they have repo access in
+		collaborators, err := getOrgOutsideCollaboratorLogins(string(org.Login), restClient)
+		if err != nil {
+			log.Printf("Unable to fetch outside collaborators for %s: %v", org.Login, err)
+			continue
+		}
+		for _, login := range collaborators {
+			if seatTypes[login] == "outside_collaborator" {
+				seatOrgs[login] = append(seatOrgs[login], string(org.Login))
+			}
+		}
+	}
+
+	usersFile, err := os.Create("license-users.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer usersFile.Close()
+
+	usersWriter := csv.NewWriter(usersFile)
+	defer usersWriter.Flush()
+
+	usersWriter.Write([]string{"Login", "Name", "User Type", "Org Count", "Orgs"})
+
+	logins := []string{}
+	for login := range seats {
+		logins = append(logins, login)
+	}
+	sort.Strings(logins)
+
+	typeSeats := map[string]int{}
+	multiOrgUsers := 0
+	for _, login := range logins {
+		typeSeats[seatTypes[login]]++
+		if len(seatOrgs[login]) > 1 {
+			multiOrgUsers++
+		}
+		usersWriter.Write([]string{
+			login,
+			seats[login].Name,
+			seatTypes[login],
+			fmt.Sprintf("%d", len(seatOrgs[login])),
+			strings.Join(seatOrgs[login], ", "),
+		})
+	}
+
+	log.Printf("Wrote %d records to license-users.csv", len(logins))
+
+	summaryFile, err := os.Create("license-report.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer summaryFile.Close()
+
+	summaryWriter := csv.NewWriter(summaryFile)
+	defer summaryWriter.Flush()
+
+	summaryWriter.Write([]string{"Category", "Name", "Seats"})
+	summaryWriter.Write([]string{"enterprise", "purchased", fmt.Sprintf("%d", billingInfo.TotalLicenses)})
+	summaryWriter.Write([]string{"enterprise", "consumed", fmt.Sprintf("%d", billingInfo.AllLicensableUsersCount)})
+	summaryWriter.Write([]string{"enterprise", "available", fmt.Sprintf("%d", billingInfo.TotalAvailableLicenses)})
+	for _, userType := range []string{"emu", "standard", "outside_collaborator"} {
+		summaryWriter.Write([]string{"user_type", userType, fmt.Sprintf("%d", typeSeats[userType])})
+	}
+	for _, org := range orgs {
+		summaryWriter.Write([]string{"org", string(org.Login), fmt.Sprintf("%d", orgSeats[string(org.Login)])})
+	}
+	summaryWriter.Write([]string{"users", "multiple_orgs", fmt.Sprintf("%d", multiOrgUsers)})
+
+	log.Printf("%d of %d seats consumed, %d users consume a seat in multiple orgs", billingInfo.AllLicensableUsersCount, billingInfo.TotalLicenses, multiOrgUsers)
+	log.Printf("Wrote license summary to license-report.csv")
+
+	return nil
+}
+
+func getEnterpriseAdmins(enterpriseSlug string, client *githubv4.Client) ([]*Member, error) {
+
+	variables := map[string]interface{}{
+		"enterpriseSlug": githubv4.String(enterpriseSlug),
+		"cursor":         (*githubv4.String)(nil),
+	}
+
+	var query struct {
+		Enterprise struct {
+			OwnerInfo struct {
+				Admins struct {
+					PageInfo struct {
+						EndCursor   githubv4.String
+						HasNextPage bool
+					}
+					Edges []struct {
+						Role string
+						Node struct {
+							Id    string
+							Login string
+							Name  string
+						}
+					}
+				} `graphql:"admins(first: 100, after: $cursor)"`
+			}
+		} `graphql:"enterprise(slug: $enterpriseSlug)"`
+		RateLimit RateLimit
+	}
+
+	allAdmins := []*Member{}
+	start := time.Now()
+	log.Printf("Fetching all admins for the %s Enterprise.", enterpriseSlug)
+	for {
+		err := client.Query(context.Background(), &query, variables)
+		if err != nil {
+			panic(err)
+		}
+
+		// check rate limit
+		if query.RateLimit.Remaining < 100 {
+			log.Printf("Rate limit: %d/%d, resets at: %s", query.RateLimit.Remaining, query.RateLimit.Limit, query.RateLimit.ResetAt)
+			time.Sleep(time.Until(query.RateLimit.ResetAt.Time))
+		}
+
+		for _, edge := range query.Enterprise.OwnerInfo.Admins.Edges {
+			allAdmins = append(allAdmins, &Member{
+				Login: edge.Node.Login,
+				Name:  edge.Node.Name,
+				Id:    edge.Node.Id,
+				Role:  edge.Role,
+			})
+		}
+
+		if !query.Enterprise.OwnerInfo.Admins.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(query.Enterprise.OwnerInfo.Admins.PageInfo.EndCursor)
+	}
+
+	log.Printf("Found %d admins in the %s Enterprise", len(allAdmins), enterpriseSlug)
+	log.Printf("Fetched all admins in %s", time.Since(start))
+
+	return allAdmins, nil
+}
@@ -0,0 +1,245 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+// EnvironmentProtection is a deployment environment and the rules that gate deployments to it.
+type EnvironmentProtection struct {
+	Name                  string
+	RequiredReviewers     []string
+	WaitTimer             int
+	BranchPolicy          string
+	CustomProtectionRules []string
+	LastDeployment        *github.Deployment
+	LastDeploymentState   string
+}
+
+// IsGated reports whether a deployment to the environment needs an approval, either from a
+// required reviewer or from a custom protection rule.
+func (e *EnvironmentProtection) IsGated() bool {
+	return len(e.RequiredReviewers) > 0 || len(e.CustomProtectionRules) > 0
+}
+
+// getCustomProtectionRules returns the slugs of the apps behind the enabled custom deployment
+// protection rules of an environment. go-github does not model this endpoint, so it is decoded here.
+func getCustomProtectionRules(orgName, repoName, environment string, client *github.Client) ([]string, error) {
+
+	var rules struct {
+		CustomDeploymentProtectionRules []struct {
+			Enabled bool `json:"enabled"`
+			App     struct {
+				Slug string `json:"slug"`
+			} `json:"app"`
+		} `json:"custom_deployment_protection_rules"`
+	}
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/environments/%s/deployment_protection_rules", orgName, repoName, url.PathEscape(environment)), nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = client.Do(context.Background(), req, &rules)
+	if err != nil {
+		return nil, err
+	}
+
+	slugs := []string{}
+	for _, rule := range rules.CustomDeploymentProtectionRules {
+		if rule.Enabled {
+			slugs = append(slugs, rule.App.Slug)
+		}
+	}
+
+	return slugs, nil
+}
+
+// getBranchPolicy describes which branches can deploy to an environment: all, protected or the
+// name patterns of a custom policy.
+func getBranchPolicy(orgName, repoName string, environment *github.Environment, client *github.Client) (string, error) {
+
+	policy := environment.DeploymentBranchPolicy
+	switch {
+	case policy == nil:
+		return "all", nil
+	case policy.GetProtectedBranches():
+		return "protected", nil
+	case policy.GetCustomBranchPolicies():
+		policies, _, err := client.Repositories.ListDeploymentBranchPolicies(context.Background(), orgName, repoName, url.PathEscape(environment.GetName()))
+		if err != nil {
+			return "", err
+		}
+		patterns := []string{}
+		for _, branchPolicy := range policies.BranchPolicies {
+			patterns = append(patterns, branchPolicy.GetName())
+		}
+		return "custom:" + strings.Join(patterns, " "), nil
+	}
+
+	return "all", nil
+}
+
+func getRepoEnvironments(orgName, repoName string, client *github.Client) ([]*EnvironmentProtection, error) {
+
+	opts := &github.EnvironmentListOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	allEnvironments := []*EnvironmentProtection{}
+	for {
+		environments, resp, err := client.Repositories.ListEnvironments(context.Background(), orgName, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, environment := range environments.Environments {
+			protection := &EnvironmentProtection{Name: environment.GetName()}
+
+			for _, rule := range environment.ProtectionRules {
+				switch rule.GetType() {
+				case "required_reviewers":
+					for _, reviewer := range rule.Reviewers {
+						switch r := reviewer.Reviewer.(type) {
+						case *github.User:
+							protection.RequiredReviewers = append(protection.RequiredReviewers, "user:"+r.GetLogin())
+						case *github.Team:
+							protection.RequiredReviewers = append(protection.RequiredReviewers, "team:"+r.GetSlug())
+						}
+					}
+				case "wait_timer":
+					protection.WaitTimer = rule.GetWaitTimer()
+				}
+			}
+
+			protection.BranchPolicy, err = getBranchPolicy(orgName, repoName, environment, client)
+			if err != nil {
+				return nil, err
+			}
+
+			// custom protection rules are not available on every server
+			protection.CustomProtectionRules, err = getCustomProtectionRules(orgName, repoName, protection.Name, client)
+			if err != nil && !isUnavailableError(err) {
+				return nil, err
+			}
+
+			deployments, _, err := client.Repositories.ListDeployments(context.Background(), orgName, repoName, &github.DeploymentsListOptions{
+				Environment: protection.Name,
+				ListOptions: github.ListOptions{PerPage: 1},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(deployments) > 0 {
+				protection.LastDeployment = deployments[0]
+
+				statuses, _, err := client.Repositories.ListDeploymentStatuses(context.Background(), orgName, repoName, deployments[0].GetID(), &github.ListOptions{PerPage: 1})
+				if err != nil {
+					return nil, err
+				}
+				if len(statuses) > 0 {
+					protection.LastDeploymentState = statuses[0].GetState()
+				}
+			}
+
+			allEnvironments = append(allEnvironments, protection)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	return allEnvironments, nil
+}
+
+// GenerateEnvironmentsReport lists every deployment environment in the enterprise with its
+// required reviewers, wait timer, deployment branch policy, custom protection rules and last
+// deployment.
+func GenerateEnvironmentsReport(enterpriseSlug string, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("environments.csv")
+	if err != nil {
+		fmt.Println("Error creating the CSV file:", err)
+	}
+
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"owner", "repo", "environment", "required_reviewers", "wait_timer_minutes", "deployment_branch_policy", "custom_protection_rules", "gated", "last_deployment_at", "last_deployment_ref", "last_deployment_creator", "last_deployment_state"}
+
+	err = writer.Write(header)
+	if err != nil {
+		fmt.Println("Error writing the header row:", err)
+		return nil
+	}
+
+	orgs, _ := getEnterpriseOrgs(enterpriseSlug, client)
+	for _, org := range orgs {
+		orgName := string(org.Login)
+
+		repos, err := getOrgRepos(orgName, false, client)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		count := 0
+		start := time.Now()
+		log.Printf("Fetching environments for the %s organization.", orgName)
+		for _, repo := range repos {
+			if repo.IsArchived {
+				continue
+			}
+
+			environments, err := getRepoEnvironments(orgName, repo.Name, restClient)
+			if err != nil {
+				log.Printf("Unable to fetch environments for %s/%s: %v", orgName, repo.Name, err)
+				continue
+			}
+
+			for _, environment := range environments {
+				deployment := environment.LastDeployment
+				lastDeploymentAt := ""
+				if deployment != nil {
+					lastDeploymentAt = formatTime(deployment.GetCreatedAt().Time)
+				}
+
+				record := []string{
+					orgName,
+					repo.Name,
+					environment.Name,
+					strings.Join(environment.RequiredReviewers, " "),
+					fmt.Sprintf("%d", environment.WaitTimer),
+					environment.BranchPolicy,
+					strings.Join(environment.CustomProtectionRules, " "),
+					fmt.Sprintf("%t", environment.IsGated()),
+					lastDeploymentAt,
+					deployment.GetRef(),
+					deployment.GetCreator().GetLogin(),
+					environment.LastDeploymentState,
+				}
+
+				err := writer.Write(record)
+				if err != nil {
+					fmt.Println("Error writing the record:", err)
+				}
+				count++
+			}
+		}
+
+		log.Printf("Found %d environments in %s in %v", count, orgName, time.Since(start))
+		log.Printf("Wrote %d records to environments.csv", count)
+	}
+
+	return nil
+}
@@ -0,0 +1,300 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+// WebhookDelivery is the outcome of the most recent delivery of a webhook.
+type WebhookDelivery struct {
+	Status      string
+	StatusCode  int
+	DeliveredAt time.Time
+}
+
+// webhookHost returns only the host of a webhook's payload URL, since the full URL can carry
+// credentials in its path or query.
+func webhookHost(hook *github.Hook) string {
+	payloadURL, _ := hook.Config["url"].(string)
+	parsed, err := url.Parse(payloadURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// lastDelivery returns the most recent delivery from a list of deliveries.
+func lastDelivery(deliveries []*github.HookDelivery) *WebhookDelivery {
+	if len(deliveries) == 0 {
+		return &WebhookDelivery{}
+	}
+	return &WebhookDelivery{
+		Status:      deliveries[0].GetStatus(),
+		StatusCode:  deliveries[0].GetStatusCode(),
+		DeliveredAt: deliveries[0].GetDeliveredAt().Time,
+	}
+}
+
+// installationPermissions formats the permissions granted to an app installation as
+// permission:access, sorted by permission.
+func installationPermissions(installation *github.Installation) (string, error) {
+
+	// every permission is a separate optional field, so they are collected through JSON
+	data, err := json.Marshal(installation.Permissions)
+	if err != nil {
+		return "", err
+	}
+
+	permissions := map[string]string{}
+	err = json.Unmarshal(data, &permissions)
+	if err != nil {
+		return "", err
+	}
+
+	granted := []string{}
+	for name, access := range permissions {
+		granted = append(granted, name+":"+access)
+	}
+	sort.Strings(granted)
+
+	return strings.Join(granted, " "), nil
+}
+
+func getOrgHooks(orgName string, client *github.Client) ([]*github.Hook, error) {
+
+	opts := &github.ListOptions{PerPage: 100}
+
+	allHooks := []*github.Hook{}
+	for {
+		hooks, resp, err := client.Organizations.ListHooks(context.Background(), orgName, opts)
+		if err != nil {
+			return nil, err
+		}
+		allHooks = append(allHooks, hooks...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allHooks, nil
+}
+
+func getRepoHooks(orgName, repoName string, client *github.Client) ([]*github.Hook, error) {
+
+	opts := &github.ListOptions{PerPage: 100}
+
+	allHooks := []*github.Hook{}
+	for {
+		hooks, resp, err := client.Repositories.ListHooks(context.Background(), orgName, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+		allHooks = append(allHooks, hooks...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allHooks, nil
+}
+
+func getOrgInstallations(orgName string, client *github.Client) ([]*github.Installation, error) {
+
+	opts := &github.ListOptions{PerPage: 100}
+
+	allInstallations := []*github.Installation{}
+	for {
+		installations, resp, err := client.Organizations.ListInstallations(context.Background(), orgName, opts)
+		if err != nil {
+			return nil, err
+		}
+		allInstallations = append(allInstallations, installations.Installations...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allInstallations, nil
+}
+
+func getRepoDeployKeys(orgName, repoName string, client *github.Client) ([]*github.Key, error) {
+
+	opts := &github.ListOptions{PerPage: 100}
+
+	allKeys := []*github.Key{}
+	for {
+		keys, resp, err := client.Repositories.ListKeys(context.Background(), orgName, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+		allKeys = append(allKeys, keys...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allKeys, nil
+}
+
+// GenerateIntegrationsReport inventories where code and events leave the enterprise. Org and
+// repository webhooks are written to integrations-webhooks.csv, GitHub App installations to
+// integrations-apps.csv and repository deploy keys to integrations-deploy-keys.csv.
+func GenerateIntegrationsReport(enterpriseSlug string, client *githubv4.Client, restClient *github.Client) error {
+
+	writers := map[string]*csv.Writer{}
+	headers := map[string][]string{
+		"integrations-webhooks.csv":    {"owner", "repo", "id", "host", "events", "active", "last_delivery_status", "last_delivery_status_code", "last_delivery_at"},
+		"integrations-apps.csv":        {"owner", "app_slug", "installation_id", "repository_selection", "permissions", "created_at", "suspended"},
+		"integrations-deploy-keys.csv": {"owner", "repo", "title", "read_only", "created_at", "age_days"},
+	}
+	for name, header := range headers {
+		file, err := os.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		writer := csv.NewWriter(file)
+		defer writer.Flush()
+
+		err = writer.Write(header)
+		if err != nil {
+			panic(err)
+		}
+		writers[name] = writer
+	}
+
+	writeHook := func(orgName, repoName string, hook *github.Hook, delivery *WebhookDelivery) {
+		record := []string{
+			orgName,
+			repoName,
+			fmt.Sprintf("%d", hook.GetID()),
+			webhookHost(hook),
+			strings.Join(hook.Events, " "),
+			fmt.Sprintf("%t", hook.GetActive()),
+			delivery.Status,
+			fmt.Sprintf("%d", delivery.StatusCode),
+			formatTime(delivery.DeliveredAt),
+		}
+		err := writers["integrations-webhooks.csv"].Write(record)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	now := time.Now()
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, org := range orgs {
+		orgName := string(org.Login)
+
+		start := time.Now()
+		log.Printf("Fetching integrations for the %s organization.", orgName)
+
+		hooks, err := getOrgHooks(orgName, restClient)
+		if err != nil {
+			log.Printf("Unable to fetch webhooks for %s: %v", orgName, err)
+		}
+		for _, hook := range hooks {
+			deliveries, _, err := restClient.Organizations.ListHookDeliveries(context.Background(), orgName, hook.GetID(), &github.ListCursorOptions{PerPage: 1})
+			if err != nil {
+				log.Printf("Unable to fetch deliveries for webhook %d in %s: %v", hook.GetID(), orgName, err)
+			}
+			writeHook(orgName, "", hook, lastDelivery(deliveries))
+		}
+
+		installations, err := getOrgInstallations(orgName, restClient)
+		if err != nil {
+			log.Printf("Unable to fetch app installations for %s: %v", orgName, err)
+		}
+		for _, installation := range installations {
+			permissions, err := installationPermissions(installation)
+			if err != nil {
+				panic(err)
+			}
+
+			record := []string{
+				orgName,
+				installation.GetAppSlug(),
+				fmt.Sprintf("%d", installation.GetID()),
+				installation.GetRepositorySelection(),
+				permissions,
+				formatTime(installation.GetCreatedAt().Time),
+				fmt.Sprintf("%t", installation.SuspendedAt != nil),
+			}
+			err = writers["integrations-apps.csv"].Write(record)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		repos, err := getOrgRepos(orgName, false, client)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, repo := range repos {
+			if repo.IsArchived {
+				continue
+			}
+
+			hooks, err := getRepoHooks(orgName, repo.Name, restClient)
+			if err != nil {
+				log.Printf("Unable to fetch webhooks for %s/%s: %v", orgName, repo.Name, err)
+			}
+			for _, hook := range hooks {
+				deliveries, _, err := restClient.Repositories.ListHookDeliveries(context.Background(), orgName, repo.Name, hook.GetID(), &github.ListCursorOptions{PerPage: 1})
+				if err != nil {
+					log.Printf("Unable to fetch deliveries for webhook %d in %s/%s: %v", hook.GetID(), orgName, repo.Name, err)
+				}
+				writeHook(orgName, repo.Name, hook, lastDelivery(deliveries))
+			}
+
+			keys, err := getRepoDeployKeys(orgName, repo.Name, restClient)
+			if err != nil {
+				log.Printf("Unable to fetch deploy keys for %s/%s: %v", orgName, repo.Name, err)
+			}
+			for _, key := range keys {
+				record := []string{
+					orgName,
+					repo.Name,
+					key.GetTitle(),
+					fmt.Sprintf("%t", key.GetReadOnly()),
+					formatTime(key.GetCreatedAt().Time),
+					fmt.Sprintf("%d", int(now.Sub(key.GetCreatedAt().Time).Hours()/24)),
+				}
+				err = writers["integrations-deploy-keys.csv"].Write(record)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+
+		log.Printf("Fetched integrations for %s in %v", orgName, time.Since(start))
+	}
+
+	return nil
+}
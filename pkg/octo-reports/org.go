@@ -3,10 +3,13 @@ package octoreports
 import (
 	"context"
 	"encoding/csv"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/google/go-github/v50/github"
 	"github.com/shurcooL/githubv4"
 )
 
@@ -126,3 +129,199 @@ func GenerateOrgMembershipReport(enterpriseSlug string, client *githubv4.Client)
 
 	return nil
 }
+
+// getOrgBasePermission returns the default permission organization members have on every
+// repository. It is only exposed through the REST API.
+func getOrgBasePermission(orgName string, client *github.Client) (string, error) {
+	settings, err := getOrgSettings(orgName, client)
+	if err != nil {
+		return "", err
+	}
+
+	return settings.DefaultRepoPermission, nil
+}
+
+type OrgSettings struct {
+	Login                                string
+	DefaultRepoPermission                string
+	MembersCanCreatePublicRepos          bool
+	MembersCanCreatePrivateRepos         bool
+	MembersCanCreateInternalRepos        bool
+	MembersCanForkPrivateRepos           bool
+	MembersCanInviteOutsideCollaborators bool
+}
+
+// Values returns the settings in the column order of the org settings report.
+func (s *OrgSettings) Values() []string {
+	return []string{
+		s.DefaultRepoPermission,
+		fmt.Sprintf("%t", s.MembersCanCreatePublicRepos),
+		fmt.Sprintf("%t", s.MembersCanCreatePrivateRepos),
+		fmt.Sprintf("%t", s.MembersCanCreateInternalRepos),
+		fmt.Sprintf("%t", s.MembersCanForkPrivateRepos),
+		fmt.Sprintf("%t", s.MembersCanInviteOutsideCollaborators),
+	}
+}
+
+var orgSettingsColumns = []string{
+	"default_repository_permission",
+	"members_can_create_public_repos",
+	"members_can_create_private_repos",
+	"members_can_create_internal_repos",
+	"members_can_fork_private_repos",
+	"members_can_invite_outside_collaborators",
+}
+
+func getOrgSettings(orgName string, client *github.Client) (*OrgSettings, error) {
+	org, _, err := client.Organizations.Get(context.Background(), orgName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrgSettings{
+		Login:                         orgName,
+		DefaultRepoPermission:         strings.ToUpper(org.GetDefaultRepoPermission()),
+		MembersCanCreatePublicRepos:   org.GetMembersCanCreatePublicRepos(),
+		MembersCanCreatePrivateRepos:  org.GetMembersCanCreatePrivateRepos(),
+		MembersCanCreateInternalRepos: org.GetMembersCanCreateInternalRepos(),
+		MembersCanForkPrivateRepos:    org.GetMembersCanForkPrivateRepos(),
+	}, nil
+}
+
+// getOrgsAllowingCollaboratorInvites returns which organizations let members invite outside
+// collaborators. The setting is only exposed through the enterprise, either as a policy for
+// every organization or, when there is no policy, as the list of organizations that allow it.
+func getOrgsAllowingCollaboratorInvites(enterpriseSlug string, orgs []*Org, client *githubv4.Client) (map[string]bool, error) {
+
+	variables := map[string]interface{}{
+		"enterpriseSlug": githubv4.String(enterpriseSlug),
+		"cursor":         (*githubv4.String)(nil),
+	}
+
+	var query struct {
+		Enterprise struct {
+			OwnerInfo struct {
+				MembersCanInviteCollaboratorsSetting              string
+				MembersCanInviteCollaboratorsSettingOrganizations struct {
+					PageInfo struct {
+						EndCursor   githubv4.String
+						HasNextPage bool
+					}
+					Nodes []struct {
+						Login string
+					}
+				} `graphql:"membersCanInviteCollaboratorsSettingOrganizations(first: 100, after: $cursor, value: true)"`
+			}
+		} `graphql:"enterprise(slug: $enterpriseSlug)"`
+		RateLimit RateLimit
+	}
+
+	allowed := map[string]bool{}
+	for {
+		err := client.Query(context.Background(), &query, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		// check rate limit
+		if query.RateLimit.Remaining < 100 {
+			log.Printf("Rate limit: %d/%d, resets at: %s", query.RateLimit.Remaining, query.RateLimit.Limit, query.RateLimit.ResetAt)
+			time.Sleep(time.Until(query.RateLimit.ResetAt.Time))
+		}
+
+		switch query.Enterprise.OwnerInfo.MembersCanInviteCollaboratorsSetting {
+		case "ENABLED", "DISABLED":
+			for _, org := range orgs {
+				allowed[string(org.Login)] = query.Enterprise.OwnerInfo.MembersCanInviteCollaboratorsSetting == "ENABLED"
+			}
+			return allowed, nil
+		}
+
+		for _, org := range query.Enterprise.OwnerInfo.MembersCanInviteCollaboratorsSettingOrganizations.Nodes {
+			allowed[org.Login] = true
+		}
+
+		if !query.Enterprise.OwnerInfo.MembersCanInviteCollaboratorsSettingOrganizations.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(query.Enterprise.OwnerInfo.MembersCanInviteCollaboratorsSettingOrganizations.PageInfo.EndCursor)
+	}
+
+	return allowed, nil
+}
+
+func GenerateOrgSettingsReport(enterpriseSlug string, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("org-settings.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := append([]string{"Org Name", "Org ID"}, orgSettingsColumns...)
+	header = append(header, "Differs From Majority")
+	err = writer.Write(header)
+	if err != nil {
+		panic(err)
+	}
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	inviteAllowed, err := getOrgsAllowingCollaboratorInvites(enterpriseSlug, orgs, client)
+	if err != nil {
+		panic(err)
+	}
+
+	allSettings := []*OrgSettings{}
+	for _, org := range orgs {
+		log.Printf("Fetching settings for %s", org.Login)
+		settings, err := getOrgSettings(string(org.Login), restClient)
+		if err != nil {
+			panic(err)
+		}
+		settings.MembersCanInviteOutsideCollaborators = inviteAllowed[string(org.Login)]
+		allSettings = append(allSettings, settings)
+	}
+
+	// without a written policy to compare against, the most common value of each setting
+	// across the enterprise is treated as the policy
+	majority := make([]string, len(orgSettingsColumns))
+	for i := range orgSettingsColumns {
+		counts := map[string]int{}
+		for _, settings := range allSettings {
+			counts[settings.Values()[i]]++
+		}
+		for value, count := range counts {
+			if count > counts[majority[i]] || (count == counts[majority[i]] && value < majority[i]) {
+				majority[i] = value
+			}
+		}
+	}
+
+	for i, settings := range allSettings {
+		values := settings.Values()
+
+		drift := []string{}
+		for j, value := range values {
+			if value != majority[j] {
+				drift = append(drift, orgSettingsColumns[j])
+			}
+		}
+
+		record := append([]string{settings.Login, string(orgs[i].ID)}, values...)
+		record = append(record, strings.Join(drift, ", "))
+		err = writer.Write(record)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	log.Printf("Wrote %d records to org-settings.csv", len(allSettings))
+
+	return nil
+}
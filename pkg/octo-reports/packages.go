@@ -3,21 +3,50 @@ package octoreports
 import (
 	"context"
 	"encoding/csv"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/google/go-github/v50/github"
 	"github.com/shurcooL/githubv4"
 )
 
 type Package struct {
-	Name       githubv4.String
-	ID         githubv4.String
-	Repository struct {
+	Name        githubv4.String
+	ID          githubv4.String
+	PackageType githubv4.String
+	Repository  struct {
 		Name githubv4.String
 	}
+	LatestVersion struct {
+		Version githubv4.String
+	}
+	Statistics struct {
+		DownloadsTotalCount int
+	}
+	Versions PackageVersions `graphql:"versions(first: 100)"`
+}
+
+type PackageVersions struct {
+	TotalCount int
+	PageInfo   PageInfo
+	Nodes      []PackageVersionNode
+}
+
+type PackageVersionNode struct {
+	ID         string
+	Version    string
+	Statistics struct {
+		DownloadsTotalCount int
+	}
 }
 
+// restPackageTypes are the package types the REST API lists packages by. Container packages
+// are only available through the REST API.
+var restPackageTypes = []string{"npm", "maven", "rubygems", "docker", "nuget", "container"}
+
 func getPackages(orgName string, client *githubv4.Client) ([]*Package, error) {
 
 	variables := map[string]interface{}{
@@ -54,9 +83,20 @@ func getPackages(orgName string, client *githubv4.Client) ([]*Package, error) {
 		}
 
 		for _, node := range query.Organization.Packages.Nodes {
+			versions, err := getRemainingPackageVersions(string(node.ID), node.Versions.PageInfo, client)
+			if err != nil {
+				panic(err)
+			}
+			node.Versions.Nodes = append(node.Versions.Nodes, versions...)
+
 			allPackages = append(allPackages, &Package{
-				Name:       node.Name,
-				Repository: node.Repository,
+				Name:          node.Name,
+				ID:            node.ID,
+				PackageType:   node.PackageType,
+				Repository:    node.Repository,
+				LatestVersion: node.LatestVersion,
+				Statistics:    node.Statistics,
+				Versions:      node.Versions,
 			})
 		}
 
@@ -70,12 +110,204 @@ func getPackages(orgName string, client *githubv4.Client) ([]*Package, error) {
 	return allPackages, nil
 }
 
-func GenerateOrgPackageReport(orgName string, client *githubv4.Client) {
-	packages, err := getPackages(orgName, client)
+// getRemainingPackageVersions fetches the versions past the first page of a package's versions.
+func getRemainingPackageVersions(packageID string, pageInfo PageInfo, client *githubv4.Client) ([]PackageVersionNode, error) {
+
+	type versionsQuery struct {
+		Node struct {
+			Package struct {
+				Versions PackageVersions `graphql:"versions(first: 100, after: $cursor)"`
+			} `graphql:"... on Package"`
+		} `graphql:"node(id: $id)"`
+	}
+
+	variables := map[string]interface{}{
+		"id": githubv4.ID(packageID),
+	}
+
+	return fetchNestedPages(client, variables, pageInfo, func(query *versionsQuery) ([]PackageVersionNode, PageInfo) {
+		return query.Node.Package.Versions.Nodes, query.Node.Package.Versions.PageInfo
+	})
+}
+
+// getOrgRestPackages lists the packages of an organization through the REST API, which is the
+// only source for package visibility and container packages. Package types the server does not
+// support are skipped.
+func getOrgRestPackages(orgName string, client *github.Client) ([]*github.Package, error) {
+
+	allPackages := []*github.Package{}
+	for _, packageType := range restPackageTypes {
+		opts := &github.PackageListOptions{
+			PackageType: github.String(packageType),
+			ListOptions: github.ListOptions{PerPage: 100},
+		}
+
+		for {
+			packages, resp, err := client.Organizations.ListPackages(context.Background(), orgName, opts)
+			if err != nil {
+				log.Printf("Unable to list %s packages for %s: %v", packageType, orgName, err)
+				break
+			}
+			allPackages = append(allPackages, packages...)
+
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.ListOptions.Page = resp.NextPage
+		}
+	}
+
+	return allPackages, nil
+}
+
+func getPackageVersions(orgName, packageType, packageName string, client *github.Client) ([]*github.PackageVersion, error) {
+
+	opts := &github.PackageListOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	allVersions := []*github.PackageVersion{}
+	for {
+		versions, resp, err := client.Organizations.PackageGetAllVersions(context.Background(), orgName, packageType, packageName, opts)
+		if err != nil {
+			return nil, err
+		}
+		allVersions = append(allVersions, versions...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	return allVersions, nil
+}
+
+// PackageDetails combines what the GraphQL and REST APIs know about a package. Download counts
+// are only available through GraphQL, which does not cover container packages, so DownloadsKnown
+// is false for packages GraphQL did not return.
+type PackageDetails struct {
+	Org            string
+	Name           string
+	Type           string
+	Visibility     string
+	Repository     string
+	VersionCount   int
+	LatestVersion  string
+	Downloads      int
+	DownloadsKnown bool
+	Versions       []*github.PackageVersion
+	VersionNodes   map[string]PackageVersionNode
+}
+
+// getOrgPackageDetails merges the REST and GraphQL views of an organization's packages, matched
+// by package type and name. Packages that only GraphQL returns are kept without a visibility.
+func getOrgPackageDetails(orgName string, client *githubv4.Client, restClient *github.Client) ([]*PackageDetails, error) {
+
+	graphqlPackages, err := getPackages(orgName, client)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := map[string]*Package{}
+	for _, pkg := range graphqlPackages {
+		byKey[strings.ToLower(string(pkg.PackageType))+"/"+string(pkg.Name)] = pkg
+	}
+
+	restPackages, err := getOrgRestPackages(orgName, restClient)
+	if err != nil {
+		return nil, err
+	}
+
+	allDetails := []*PackageDetails{}
+	for _, restPackage := range restPackages {
+		details := &PackageDetails{
+			Org:          orgName,
+			Name:         restPackage.GetName(),
+			Type:         restPackage.GetPackageType(),
+			Visibility:   restPackage.GetVisibility(),
+			Repository:   restPackage.GetRepository().GetName(),
+			VersionCount: int(restPackage.GetVersionCount()),
+			VersionNodes: map[string]PackageVersionNode{},
+		}
+
+		details.Versions, err = getPackageVersions(orgName, details.Type, details.Name, restClient)
+		if err != nil {
+			log.Printf("Unable to fetch versions of %s/%s: %v", orgName, details.Name, err)
+		}
+		// versions are returned newest first
+		if len(details.Versions) > 0 {
+			details.LatestVersion = details.Versions[0].GetName()
+		}
+
+		key := details.Type + "/" + details.Name
+		if pkg, ok := byKey[key]; ok {
+			if pkg.LatestVersion.Version != "" {
+				details.LatestVersion = string(pkg.LatestVersion.Version)
+			}
+			details.Downloads = pkg.Statistics.DownloadsTotalCount
+			details.DownloadsKnown = true
+			for _, version := range pkg.Versions.Nodes {
+				details.VersionNodes[version.Version] = version
+			}
+			delete(byKey, key)
+		}
+
+		allDetails = append(allDetails, details)
+	}
+
+	for _, pkg := range graphqlPackages {
+		if _, ok := byKey[strings.ToLower(string(pkg.PackageType))+"/"+string(pkg.Name)]; !ok {
+			continue
+		}
+		details := &PackageDetails{
+			Org:            orgName,
+			Name:           string(pkg.Name),
+			Type:           strings.ToLower(string(pkg.PackageType)),
+			Repository:     string(pkg.Repository.Name),
+			VersionCount:   pkg.Versions.TotalCount,
+			LatestVersion:  string(pkg.LatestVersion.Version),
+			Downloads:      pkg.Statistics.DownloadsTotalCount,
+			DownloadsKnown: true,
+			VersionNodes:   map[string]PackageVersionNode{},
+		}
+		for _, version := range pkg.Versions.Nodes {
+			details.VersionNodes[version.Version] = version
+		}
+		allDetails = append(allDetails, details)
+	}
+
+	return allDetails, nil
+}
+
+// GenerateOrgPackageReport writes the packages of a single organization to packages.csv and their
+// versions to package-versions.csv.
+func GenerateOrgPackageReport(orgName string, client *githubv4.Client, restClient *github.Client) {
+	writePackageReport([]string{orgName}, client, restClient)
+}
+
+// GenerateEnterprisePackageReport writes the packages of every organization in the enterprise to
+// packages.csv and their versions to package-versions.csv.
+func GenerateEnterprisePackageReport(enterpriseSlug string, client *githubv4.Client, restClient *github.Client) {
+	writePackageReport(enterpriseOrgNames(enterpriseSlug, client), client, restClient)
+}
+
+// enterpriseOrgNames returns the logins of every organization in the enterprise.
+func enterpriseOrgNames(enterpriseSlug string, client *githubv4.Client) []string {
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
 	if err != nil {
 		panic(err)
 	}
 
+	orgNames := []string{}
+	for _, org := range orgs {
+		orgNames = append(orgNames, string(org.Login))
+	}
+
+	return orgNames
+}
+
+func writePackageReport(orgNames []string, client *githubv4.Client, restClient *github.Client) {
 	file, err := os.Create("packages.csv")
 	if err != nil {
 		panic(err)
@@ -85,9 +317,68 @@ func GenerateOrgPackageReport(orgName string, client *githubv4.Client) {
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	writer.Write([]string{"Package Name", "Repository Name"})
+	writer.Write([]string{"Package Name", "Repository Name", "Organization", "Package Type", "Visibility", "Version Count", "Latest Version", "Total Downloads"})
+
+	versionsFile, err := os.Create("package-versions.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer versionsFile.Close()
+
+	versionsWriter := csv.NewWriter(versionsFile)
+	defer versionsWriter.Flush()
+
+	versionsWriter.Write([]string{"Organization", "Package Name", "Package Type", "Version", "Created At", "Downloads", "Tags"})
+
+	for _, orgName := range orgNames {
+		packages, err := getOrgPackageDetails(orgName, client, restClient)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, pkg := range packages {
+			// unknown download counts are left blank so they are not read as never downloaded
+			downloads := ""
+			if pkg.DownloadsKnown {
+				downloads = fmt.Sprintf("%d", pkg.Downloads)
+			}
+			writer.Write([]string{
+				pkg.Name,
+				pkg.Repository,
+				pkg.Org,
+				pkg.Type,
+				pkg.Visibility,
+				fmt.Sprintf("%d", pkg.VersionCount),
+				pkg.LatestVersion,
+				downloads,
+			})
+
+			for _, version := range pkg.Versions {
+				downloads := ""
+				if node, ok := pkg.VersionNodes[version.GetName()]; ok {
+					downloads = fmt.Sprintf("%d", node.Statistics.DownloadsTotalCount)
+				}
+				versionsWriter.Write([]string{
+					pkg.Org,
+					pkg.Name,
+					pkg.Type,
+					version.GetName(),
+					formatTime(version.GetCreatedAt().Time),
+					downloads,
+					strings.Join(versionTags(version), " "),
+				})
+			}
+		}
+
+		log.Printf("Wrote %d packages for %s to packages.csv", len(packages), orgName)
+	}
+}
 
-	for _, pkg := range packages {
-		writer.Write([]string{string(pkg.Name), string(pkg.Repository.Name)})
+// versionTags returns the container tags of a package version.
+func versionTags(version *github.PackageVersion) []string {
+	container := version.GetMetadata().GetContainer()
+	if container == nil {
+		return nil
 	}
+	return container.Tags
 }
@@ -0,0 +1,52 @@
+package octoreports
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+type PageInfo struct {
+	EndCursor   githubv4.String
+	HasNextPage bool
+}
+
+// fetchNestedPages fetches the rest of a connection that is nested inside another connection.
+// GraphQL only lets the outer connection be paginated with a cursor, so inner connections such
+// as a repository's topics or a team's child teams are truncated at their first page.
+//
+// pageInfo is the page info of the truncated inner connection; when it has no next page nothing
+// is fetched. Otherwise Q is queried, usually through node(id: $id), with the $cursor variable
+// set to the end of the last page until extract reports there are no more pages. variables must
+// hold everything Q needs apart from the cursor.
+func fetchNestedPages[Q any, N any](client *githubv4.Client, variables map[string]interface{}, pageInfo PageInfo, extract func(*Q) ([]N, PageInfo)) ([]N, error) {
+
+	nodes := []N{}
+	for pageInfo.HasNextPage {
+		variables["cursor"] = githubv4.NewString(pageInfo.EndCursor)
+
+		var query struct {
+			Page      Q `graphql:"... on Query"`
+			RateLimit RateLimit
+		}
+
+		err := client.Query(context.Background(), &query, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		// check rate limit
+		if query.RateLimit.Remaining < 100 {
+			log.Printf("Rate limit: %d/%d, resets at: %s", query.RateLimit.Remaining, query.RateLimit.Limit, query.RateLimit.ResetAt)
+			time.Sleep(time.Until(query.RateLimit.ResetAt.Time))
+		}
+
+		var page []N
+		page, pageInfo = extract(&query.Page)
+		nodes = append(nodes, page...)
+	}
+
+	return nodes, nil
+}
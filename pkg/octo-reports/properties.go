@@ -0,0 +1,385 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+// propertyValueSeparator joins the values of multi_select properties in a CSV cell.
+const propertyValueSeparator = ";"
+
+// maxPropertyRepos is the most repositories the API sets properties on in one request.
+const maxPropertyRepos = 30
+
+// PropertySchema is a custom property defined by an organization. go-github v50 does not model
+// custom properties, so the responses are decoded here.
+type PropertySchema struct {
+	Name          string   `json:"property_name"`
+	ValueType     string   `json:"value_type"`
+	Required      bool     `json:"required"`
+	AllowedValues []string `json:"allowed_values"`
+}
+
+type propertyValue struct {
+	Name  string      `json:"property_name"`
+	Value interface{} `json:"value"`
+}
+
+// propertyValueString flattens a property value, which is a string, a list of strings for
+// multi_select properties or null, into a CSV cell.
+func propertyValueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			values = append(values, fmt.Sprintf("%v", item))
+		}
+		return strings.Join(values, propertyValueSeparator)
+	}
+	return ""
+}
+
+func getOrgPropertySchema(orgName string, client *github.Client) ([]*PropertySchema, error) {
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("orgs/%s/properties/schema", orgName), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := []*PropertySchema{}
+	_, err = client.Do(context.Background(), req, &schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// getOrgPropertyValues returns the custom property values of every repository in an
+// organization, keyed by repository name and then property name.
+func getOrgPropertyValues(orgName string, client *github.Client) (map[string]map[string]string, error) {
+
+	allValues := map[string]map[string]string{}
+	page := 1
+	for {
+		req, err := client.NewRequest("GET", fmt.Sprintf("orgs/%s/properties/values?per_page=100&page=%d", orgName, page), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var repos []struct {
+			RepositoryName string          `json:"repository_name"`
+			Properties     []propertyValue `json:"properties"`
+		}
+		resp, err := client.Do(context.Background(), req, &repos)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range repos {
+			values := map[string]string{}
+			for _, property := range repo.Properties {
+				values[property.Name] = propertyValueString(property.Value)
+			}
+			allValues[repo.RepositoryName] = values
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		page = resp.NextPage
+	}
+
+	return allValues, nil
+}
+
+// GeneratePropertiesReport writes every repository in the enterprise to repo-properties.csv with
+// the columns of the repo report, including the selected optional fields, followed by one column
+// per custom property defined in any of its organizations.
+func GeneratePropertiesReport(enterpriseSlug string, fields []string, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("repo-properties.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	// the columns depend on every organization's schema, so everything is fetched before writing
+	orgRepos := map[string][]*Repo{}
+	orgValues := map[string]map[string]map[string]string{}
+	propertyNames := []string{}
+	for _, org := range orgs {
+		orgName := string(org.Login)
+
+		repos, err := getOrgReposWithFields(orgName, true, fields, client)
+		if err != nil {
+			panic(err)
+		}
+		orgRepos[orgName] = repos
+
+		schema, err := getOrgPropertySchema(orgName, restClient)
+		if err != nil {
+			log.Printf("Unable to fetch custom properties for %s: %v", orgName, err)
+			continue
+		}
+		for _, property := range schema {
+			if !contains(propertyNames, property.Name) {
+				propertyNames = append(propertyNames, property.Name)
+			}
+		}
+
+		orgValues[orgName], err = getOrgPropertyValues(orgName, restClient)
+		if err != nil {
+			log.Printf("Unable to fetch custom property values for %s: %v", orgName, err)
+		}
+	}
+	sort.Strings(propertyNames)
+
+	header := append(repoReportHeader(fields), propertyNames...)
+	err = writer.Write(header)
+	if err != nil {
+		panic(err)
+	}
+
+	count := 0
+	for _, org := range orgs {
+		orgName := string(org.Login)
+		for _, repo := range orgRepos[orgName] {
+			record := repoReportRecord(repo, fields)
+			values := orgValues[orgName][repo.Name]
+			for _, name := range propertyNames {
+				record = append(record, values[name])
+			}
+
+			err = writer.Write(record)
+			if err != nil {
+				panic(err)
+			}
+			count++
+		}
+	}
+
+	log.Printf("Wrote %d records to repo-properties.csv", count)
+
+	return nil
+}
+
+// propertyChange is a custom property value to set on a repository.
+type propertyChange struct {
+	Owner    string
+	Repo     string
+	Property *PropertySchema
+	Current  string
+	Value    string
+}
+
+// apiValue returns the value in the form the API expects for the property's type.
+func (c *propertyChange) apiValue() interface{} {
+	if c.Property.ValueType == "multi_select" {
+		return strings.Split(c.Value, propertyValueSeparator)
+	}
+	return c.Value
+}
+
+// validate checks the value against the values a select property allows.
+func (c *propertyChange) validate() error {
+	if len(c.Property.AllowedValues) == 0 {
+		return nil
+	}
+
+	values := []string{c.Value}
+	if c.Property.ValueType == "multi_select" {
+		values = strings.Split(c.Value, propertyValueSeparator)
+	}
+	for _, value := range values {
+		if !contains(c.Property.AllowedValues, value) {
+			return fmt.Errorf("%q is not one of %v", value, c.Property.AllowedValues)
+		}
+	}
+
+	return nil
+}
+
+// readPropertyRows reads the repositories to update from a CSV file with owner and name columns,
+// such as repo-properties.csv. Rows are grouped by owner and keyed by column name.
+func readPropertyRows(path string) (map[string][]map[string]string, []string, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !contains(header, "owner") || !contains(header, "name") {
+		return nil, nil, fmt.Errorf("%s must have owner and name columns", path)
+	}
+
+	rows := map[string][]map[string]string{}
+	owners := []string{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		row := map[string]string{}
+		for i, column := range header {
+			row[column] = strings.TrimSpace(record[i])
+		}
+		if _, ok := rows[row["owner"]]; !ok {
+			owners = append(owners, row["owner"])
+		}
+		rows[row["owner"]] = append(rows[row["owner"]], row)
+	}
+
+	return rows, owners, nil
+}
+
+// setOrgPropertyValues sets the same property values on a batch of repositories in an
+// organization.
+func setOrgPropertyValues(orgName string, repoNames []string, changes []*propertyChange, client *github.Client) error {
+
+	properties := []propertyValue{}
+	for _, change := range changes {
+		properties = append(properties, propertyValue{Name: change.Property.Name, Value: change.apiValue()})
+	}
+
+	body := struct {
+		RepositoryNames []string        `json:"repository_names"`
+		Properties      []propertyValue `json:"properties"`
+	}{repoNames, properties}
+
+	req, err := client.NewRequest("PATCH", fmt.Sprintf("orgs/%s/properties/values", orgName), body)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(context.Background(), req, nil)
+
+	return err
+}
+
+// ApplyRepoProperties sets custom property values in bulk from a CSV file with owner and name
+// columns and one column per property, such as an edited repo-properties.csv. Columns that are not
+// custom properties of the repository's organization are ignored, as are blank cells. The changes
+// are printed as a diff, and only written when apply is set.
+func ApplyRepoProperties(from string, apply bool, client *github.Client) error {
+
+	rows, owners, err := readPropertyRows(from)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	invalid := 0
+	for _, orgName := range owners {
+		schema, err := getOrgPropertySchema(orgName, client)
+		if err != nil {
+			return fmt.Errorf("unable to fetch custom properties for %s: %v", orgName, err)
+		}
+		current, err := getOrgPropertyValues(orgName, client)
+		if err != nil {
+			return fmt.Errorf("unable to fetch custom property values for %s: %v", orgName, err)
+		}
+
+		// repositories that need the same changes are updated together
+		batches := map[string][]string{}
+		batchChanges := map[string][]*propertyChange{}
+		keys := []string{}
+		for _, row := range rows[orgName] {
+			repoName := row["name"]
+			values, ok := current[repoName]
+			if !ok {
+				log.Printf("Skipping %s/%s, the repository was not found", orgName, repoName)
+				continue
+			}
+
+			changes := []*propertyChange{}
+			for _, property := range schema {
+				value, ok := row[property.Name]
+				if !ok || value == "" || value == values[property.Name] {
+					continue
+				}
+
+				change := &propertyChange{orgName, repoName, property, values[property.Name], value}
+				if err := change.validate(); err != nil {
+					fmt.Printf("! %s/%s %s: %v\n", orgName, repoName, property.Name, err)
+					invalid++
+					continue
+				}
+				fmt.Printf("~ %s/%s %s: %q -> %q\n", orgName, repoName, property.Name, change.Current, change.Value)
+				changes = append(changes, change)
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			total += len(changes)
+
+			parts := []string{}
+			for _, change := range changes {
+				parts = append(parts, change.Property.Name+"="+change.Value)
+			}
+			key := strings.Join(parts, "\n")
+			if _, ok := batches[key]; !ok {
+				keys = append(keys, key)
+				batchChanges[key] = changes
+			}
+			batches[key] = append(batches[key], repoName)
+		}
+
+		if !apply {
+			continue
+		}
+
+		for _, key := range keys {
+			repoNames := batches[key]
+			for start := 0; start < len(repoNames); start += maxPropertyRepos {
+				end := start + maxPropertyRepos
+				if end > len(repoNames) {
+					end = len(repoNames)
+				}
+				err := setOrgPropertyValues(orgName, repoNames[start:end], batchChanges[key], client)
+				if err != nil {
+					return fmt.Errorf("unable to set custom properties in %s: %v", orgName, err)
+				}
+			}
+			log.Printf("Updated %d repositories in %s", len(repoNames), orgName)
+		}
+	}
+
+	if invalid > 0 {
+		log.Printf("Skipped %d invalid values", invalid)
+	}
+	if !apply {
+		log.Printf("Dry run: %d property changes, pass -apply to write them", total)
+		return nil
+	}
+	log.Printf("Applied %d property changes", total)
+
+	return nil
+}
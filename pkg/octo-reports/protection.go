@@ -0,0 +1,316 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// BranchProtection is the combined effect of the classic branch protection rule and
+// the active rulesets that apply to a branch.
+type BranchProtection struct {
+	IsProtected             bool
+	RequiredReviews         int
+	RequiresCodeOwnerReview bool
+	RequiredStatusChecks    []string
+	AllowsForcePushes       bool
+	AllowsDeletions         bool
+	IsAdminEnforced         bool
+	ClassicRule             bool
+	Rulesets                []string
+}
+
+// RefRule is a ruleset rule that applies to a ref.
+type RefRule struct {
+	Type       string
+	Parameters struct {
+		PullRequestParameters struct {
+			RequiredApprovingReviewCount int
+			RequireCodeOwnerReview       bool
+		} `graphql:"... on PullRequestParameters"`
+		RequiredStatusChecksParameters struct {
+			RequiredStatusChecks []struct {
+				Context string
+			}
+		} `graphql:"... on RequiredStatusChecksParameters"`
+	}
+	RepositoryRuleset struct {
+		Name         string
+		Enforcement  string
+		BypassActors struct {
+			TotalCount int
+		} `graphql:"bypassActors(first: 1)"`
+	}
+}
+
+type RefRules struct {
+	PageInfo PageInfo
+	Nodes    []RefRule
+}
+
+type refRulesQuery struct {
+	Node struct {
+		Ref struct {
+			Rules RefRules `graphql:"rules(first: 25, after: $cursor)"`
+		} `graphql:"... on Ref"`
+	} `graphql:"node(id: $id)"`
+}
+
+func getOrgBranchProtection(orgName string, client *githubv4.Client) ([]*Repo, error) {
+
+	variables := map[string]interface{}{
+		"orgName": githubv4.String(orgName),
+		"cursor":  (*githubv4.String)(nil),
+	}
+
+	var query struct {
+		Organization struct {
+			Repositories struct {
+				PageInfo struct {
+					EndCursor   githubv4.String
+					HasNextPage bool
+				}
+				Nodes []struct {
+					Name       string
+					ID         string
+					IsArchived bool
+					Owner      struct {
+						Login string
+					}
+					DefaultBranchRef *struct {
+						ID                   string
+						Name                 string
+						BranchProtectionRule *struct {
+							RequiresApprovingReviews     bool
+							RequiredApprovingReviewCount int
+							RequiresCodeOwnerReviews     bool
+							RequiresStatusChecks         bool
+							RequiredStatusCheckContexts  []string
+							AllowsForcePushes            bool
+							AllowsDeletions              bool
+							IsAdminEnforced              bool
+						}
+						Rules RefRules `graphql:"rules(first: 25)"`
+					}
+				}
+			} `graphql:"repositories(first: 100, after: $cursor)"`
+		} `graphql:"organization(login: $orgName)"`
+		RateLimit RateLimit
+	}
+
+	allRepos := []*Repo{}
+	start := time.Now()
+	log.Printf("Fetching default branch protection for the %s organization.", orgName)
+	for {
+		err := client.Query(context.Background(), &query, variables)
+		if err != nil {
+			panic(err)
+		}
+
+		// check rate limit
+		if query.RateLimit.Remaining < 100 {
+			log.Printf("Rate limit: %d/%d, resets at: %s", query.RateLimit.Remaining, query.RateLimit.Limit, query.RateLimit.ResetAt)
+			time.Sleep(time.Until(query.RateLimit.ResetAt.Time))
+		}
+
+		for _, repo := range query.Organization.Repositories.Nodes {
+
+			// empty repositories have no default branch to protect
+			if repo.DefaultBranchRef == nil {
+				allRepos = append(allRepos, &Repo{
+					Name:       repo.Name,
+					ID:         repo.ID,
+					IsArchived: repo.IsArchived,
+					Owner:      repo.Owner.Login,
+				})
+				continue
+			}
+
+			protection := &BranchProtection{
+				AllowsForcePushes: true,
+				AllowsDeletions:   true,
+			}
+
+			if rule := repo.DefaultBranchRef.BranchProtectionRule; rule != nil {
+				protection.IsProtected = true
+				protection.ClassicRule = true
+				if rule.RequiresApprovingReviews {
+					protection.RequiredReviews = rule.RequiredApprovingReviewCount
+				}
+				protection.RequiresCodeOwnerReview = rule.RequiresCodeOwnerReviews
+				if rule.RequiresStatusChecks {
+					protection.RequiredStatusChecks = append(protection.RequiredStatusChecks, rule.RequiredStatusCheckContexts...)
+				}
+				protection.AllowsForcePushes = rule.AllowsForcePushes
+				protection.AllowsDeletions = rule.AllowsDeletions
+				protection.IsAdminEnforced = rule.IsAdminEnforced
+			}
+
+			rules := repo.DefaultBranchRef.Rules.Nodes
+			moreRules, err := fetchNestedPages(client, map[string]interface{}{
+				"id": githubv4.ID(repo.DefaultBranchRef.ID),
+			}, repo.DefaultBranchRef.Rules.PageInfo, func(query *refRulesQuery) ([]RefRule, PageInfo) {
+				return query.Node.Ref.Rules.Nodes, query.Node.Ref.Rules.PageInfo
+			})
+			if err != nil {
+				panic(err)
+			}
+			rules = append(rules, moreRules...)
+
+			for _, rule := range rules {
+				// rulesets in evaluate mode or disabled do not block anything
+				if rule.RepositoryRuleset.Enforcement != "ACTIVE" {
+					continue
+				}
+				protection.IsProtected = true
+
+				if !contains(protection.Rulesets, rule.RepositoryRuleset.Name) {
+					protection.Rulesets = append(protection.Rulesets, rule.RepositoryRuleset.Name)
+				}
+				// a ruleset without bypass actors applies to admins as well
+				if rule.RepositoryRuleset.BypassActors.TotalCount == 0 {
+					protection.IsAdminEnforced = true
+				}
+
+				switch rule.Type {
+				case "PULL_REQUEST":
+					if rule.Parameters.PullRequestParameters.RequiredApprovingReviewCount > protection.RequiredReviews {
+						protection.RequiredReviews = rule.Parameters.PullRequestParameters.RequiredApprovingReviewCount
+					}
+					if rule.Parameters.PullRequestParameters.RequireCodeOwnerReview {
+						protection.RequiresCodeOwnerReview = true
+					}
+				case "REQUIRED_STATUS_CHECKS":
+					for _, check := range rule.Parameters.RequiredStatusChecksParameters.RequiredStatusChecks {
+						if !contains(protection.RequiredStatusChecks, check.Context) {
+							protection.RequiredStatusChecks = append(protection.RequiredStatusChecks, check.Context)
+						}
+					}
+				case "NON_FAST_FORWARD":
+					protection.AllowsForcePushes = false
+				case "DELETION":
+					protection.AllowsDeletions = false
+				}
+			}
+
+			allRepos = append(allRepos, &Repo{
+				Name:          repo.Name,
+				ID:            repo.ID,
+				IsArchived:    repo.IsArchived,
+				Owner:         repo.Owner.Login,
+				DefaultBranch: repo.DefaultBranchRef.Name,
+				Protection:    protection,
+			})
+		}
+
+		if !query.Organization.Repositories.PageInfo.HasNextPage {
+			break
+		}
+
+		variables["cursor"] = githubv4.NewString(query.Organization.Repositories.PageInfo.EndCursor)
+	}
+
+	log.Printf("Found %d repositories in %s", len(allRepos), orgName)
+	log.Printf("Fetched default branch protection in %v", time.Since(start))
+
+	return allRepos, nil
+}
+
+func GenerateBranchProtectionReport(enterpriseSlug string, client *githubv4.Client) error {
+	file, err := os.Create("branch-protection.csv")
+	if err != nil {
+		fmt.Println("Error creating the CSV file:", err)
+	}
+
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"id", "owner", "name", "archived", "default_branch", "protected", "classic_rule", "rulesets", "required_reviews", "code_owner_reviews", "status_checks", "allows_force_pushes", "allows_deletions", "admin_enforced", "needs_remediation"}
+
+	err = writer.Write(header)
+	if err != nil {
+		fmt.Println("Error writing the header row:", err)
+		return nil
+	}
+
+	orgs, _ := getEnterpriseOrgs(enterpriseSlug, client)
+	unprotected := 0
+	for _, org := range orgs {
+
+		repos, err := getOrgBranchProtection(string(org.Login), client)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, repo := range repos {
+			if repo.Protection == nil {
+				record := []string{
+					repo.ID,
+					repo.Owner,
+					repo.Name,
+					fmt.Sprintf("%t", repo.IsArchived),
+					"", "", "", "", "", "", "", "", "", "",
+					"false",
+				}
+				err := writer.Write(record)
+				if err != nil {
+					fmt.Println("Error writing the record:", err)
+				}
+				continue
+			}
+
+			// archived repositories are read-only, so they are never flagged
+			needsRemediation := !repo.Protection.IsProtected && !repo.IsArchived
+			if needsRemediation {
+				unprotected++
+			}
+
+			record := []string{
+				repo.ID,
+				repo.Owner,
+				repo.Name,
+				fmt.Sprintf("%t", repo.IsArchived),
+				repo.DefaultBranch,
+				fmt.Sprintf("%t", repo.Protection.IsProtected),
+				fmt.Sprintf("%t", repo.Protection.ClassicRule),
+				strings.Join(repo.Protection.Rulesets, ", "),
+				fmt.Sprintf("%d", repo.Protection.RequiredReviews),
+				fmt.Sprintf("%t", repo.Protection.RequiresCodeOwnerReview),
+				strings.Join(repo.Protection.RequiredStatusChecks, ", "),
+				fmt.Sprintf("%t", repo.Protection.AllowsForcePushes),
+				fmt.Sprintf("%t", repo.Protection.AllowsDeletions),
+				fmt.Sprintf("%t", repo.Protection.IsAdminEnforced),
+				fmt.Sprintf("%t", needsRemediation),
+			}
+
+			err := writer.Write(record)
+			if err != nil {
+				fmt.Println("Error writing the record:", err)
+			}
+		}
+
+		log.Printf("Wrote %d records to branch-protection.csv", len(repos))
+	}
+
+	log.Printf("Found %d repositories with an unprotected default branch", unprotected)
+
+	return nil
+}
+
+// contains reports whether value is present in values.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+// getPackageVersionSize returns the total size in bytes of the files of a package version. The
+// size is only known for package versions that GraphQL returns, which excludes containers.
+func getPackageVersionSize(versionID string, client *githubv4.Client) (int64, error) {
+
+	variables := map[string]interface{}{
+		"id":     githubv4.ID(versionID),
+		"cursor": (*githubv4.String)(nil),
+	}
+
+	var query struct {
+		Node struct {
+			PackageVersion struct {
+				Files struct {
+					PageInfo PageInfo
+					Nodes    []struct {
+						Size int64
+					}
+				} `graphql:"files(first: 100, after: $cursor)"`
+			} `graphql:"... on PackageVersion"`
+		} `graphql:"node(id: $id)"`
+		RateLimit RateLimit
+	}
+
+	var size int64
+	for {
+		err := client.Query(context.Background(), &query, variables)
+		if err != nil {
+			return 0, err
+		}
+
+		// check rate limit
+		if query.RateLimit.Remaining < 100 {
+			log.Printf("Rate limit: %d/%d, resets at: %s", query.RateLimit.Remaining, query.RateLimit.Limit, query.RateLimit.ResetAt)
+			time.Sleep(time.Until(query.RateLimit.ResetAt.Time))
+		}
+
+		for _, file := range query.Node.PackageVersion.Files.Nodes {
+			size += file.Size
+		}
+
+		if !query.Node.PackageVersion.Files.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(query.Node.PackageVersion.Files.PageInfo.EndCursor)
+	}
+
+	return size, nil
+}
+
+// pruneOptions are the limits a package version is pruned by.
+type pruneOptions struct {
+	cutoff                  time.Time
+	olderThanDays           int
+	keepLast                int
+	includeUnknownDownloads bool
+}
+
+// pruneReason returns why a package version should be pruned, or an empty string when it should
+// be kept. position is the version's place in the newest first list of versions. The newest
+// version, tagged versions and versions with any recorded downloads are always kept; GitHub does
+// not expose when a version was last downloaded, so any download counts as recent. Versions whose
+// downloads are not known are kept unless includeUnknownDownloads is set. Container versions are
+// always kept when their downloads are not known, which is always: the platform manifests of a
+// multi-arch image are untagged versions referenced by the tagged index, and deleting them breaks
+// the tagged image.
+func pruneReason(version *github.PackageVersion, packageType string, position int, downloads int, downloadsKnown bool, opts pruneOptions) string {
+
+	if position == 0 || len(versionTags(version)) > 0 || downloads > 0 {
+		return ""
+	}
+	if !downloadsKnown && (!opts.includeUnknownDownloads || packageType == "container") {
+		return ""
+	}
+
+	reasons := []string{}
+	if opts.olderThanDays > 0 && version.GetCreatedAt().Time.Before(opts.cutoff) {
+		reasons = append(reasons, fmt.Sprintf("older_than_%d_days", opts.olderThanDays))
+	}
+	if opts.keepLast > 0 && position >= opts.keepLast {
+		reasons = append(reasons, fmt.Sprintf("beyond_last_%d", opts.keepLast))
+	}
+	if len(reasons) > 0 && !downloadsKnown {
+		reasons = append(reasons, "downloads_unknown")
+	}
+
+	return strings.Join(reasons, ", ")
+}
+
+// GeneratePackagePruneReport finds package versions that are older than olderThanDays days or
+// beyond the newest keepLast versions of their package, and writes them to package-prune.csv with
+// the estimated storage their deletion would reclaim. Either limit is ignored when it is 0. When
+// apply is set the versions are deleted and the outcome of every deletion is recorded in the CSV.
+// Only orgName is searched when it is set, otherwise every organization in the enterprise.
+// Versions with unknown download counts are only pruned when includeUnknownDownloads is set.
+func GeneratePackagePruneReport(orgName, enterpriseSlug string, olderThanDays, keepLast int, includeUnknownDownloads, apply bool, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("package-prune.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"organization", "package_name", "package_type", "version_id", "version", "created_at", "downloads", "reasons", "size_bytes", "action", "deleted_at"}
+	err = writer.Write(header)
+	if err != nil {
+		panic(err)
+	}
+
+	opts := pruneOptions{
+		cutoff:                  time.Now().AddDate(0, 0, -olderThanDays),
+		olderThanDays:           olderThanDays,
+		keepLast:                keepLast,
+		includeUnknownDownloads: includeUnknownDownloads,
+	}
+
+	orgNames := []string{orgName}
+	if orgName == "" {
+		orgNames = enterpriseOrgNames(enterpriseSlug, client)
+	}
+
+	candidates := 0
+	deleted := 0
+	unknownSizes := 0
+	var reclaimable int64
+	for _, orgName := range orgNames {
+		packages, err := getOrgPackageDetails(orgName, client, restClient)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, pkg := range packages {
+			for position, version := range pkg.Versions {
+				node, known := pkg.VersionNodes[version.GetName()]
+
+				reason := pruneReason(version, pkg.Type, position, node.Statistics.DownloadsTotalCount, known, opts)
+				if reason == "" {
+					continue
+				}
+				candidates++
+
+				downloads := ""
+				if known {
+					downloads = fmt.Sprintf("%d", node.Statistics.DownloadsTotalCount)
+				}
+
+				size := ""
+				if known {
+					bytes, err := getPackageVersionSize(node.ID, client)
+					if err != nil {
+						log.Printf("Unable to fetch the size of %s %s: %v", pkg.Name, version.GetName(), err)
+						unknownSizes++
+					} else {
+						size = fmt.Sprintf("%d", bytes)
+						reclaimable += bytes
+					}
+				} else {
+					unknownSizes++
+				}
+
+				action := "would_delete"
+				deletedAt := ""
+				if apply {
+					_, err := restClient.Organizations.PackageDeleteVersion(context.Background(), orgName, pkg.Type, pkg.Name, version.GetID())
+					if err != nil {
+						action = "delete_failed"
+						log.Printf("Unable to delete %s/%s %s: %v", orgName, pkg.Name, version.GetName(), err)
+					} else {
+						action = "deleted"
+						deletedAt = time.Now().Format(time.RFC3339)
+						deleted++
+						log.Printf("Deleted %s/%s %s (%s)", orgName, pkg.Name, version.GetName(), reason)
+					}
+				}
+
+				record := []string{
+					orgName,
+					pkg.Name,
+					pkg.Type,
+					fmt.Sprintf("%d", version.GetID()),
+					version.GetName(),
+					formatTime(version.GetCreatedAt().Time),
+					downloads,
+					reason,
+					size,
+					action,
+					deletedAt,
+				}
+				err = writer.Write(record)
+				if err != nil {
+					panic(err)
+				}
+				// keep the audit trail on disk in case a later deletion fails hard
+				if apply {
+					writer.Flush()
+				}
+			}
+		}
+	}
+
+	log.Printf("Found %d package versions to prune", candidates)
+	log.Printf("Estimated reclaimable storage: %.2f MB, %d versions have an unknown size", float64(reclaimable)/1024/1024, unknownSizes)
+	if apply {
+		log.Printf("Deleted %d package versions", deleted)
+	}
+	log.Printf("Wrote %d records to package-prune.csv", candidates)
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package octoreports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+)
+
+func TestPruneReason(t *testing.T) {
+	now := time.Now()
+	old := &github.PackageVersion{CreatedAt: &github.Timestamp{Time: now.AddDate(0, 0, -200)}}
+	recent := &github.PackageVersion{CreatedAt: &github.Timestamp{Time: now.AddDate(0, 0, -10)}}
+	tagged := &github.PackageVersion{
+		CreatedAt: &github.Timestamp{Time: now.AddDate(0, 0, -200)},
+		Metadata: &github.PackageMetadata{
+			Container: &github.PackageContainerMetadata{Tags: []string{"latest"}},
+		},
+	}
+
+	opts := pruneOptions{cutoff: now.AddDate(0, 0, -180), olderThanDays: 180, keepLast: 5}
+	unknownOpts := opts
+	unknownOpts.includeUnknownDownloads = true
+
+	tests := []struct {
+		name           string
+		version        *github.PackageVersion
+		packageType    string
+		position       int
+		downloads      int
+		downloadsKnown bool
+		opts           pruneOptions
+		want           string
+	}{
+		{"newest version is kept", old, "npm", 0, 0, true, opts, ""},
+		{"tagged version is kept", tagged, "npm", 10, 0, true, opts, ""},
+		{"downloaded version is kept", old, "npm", 10, 1, true, opts, ""},
+		{"unknown downloads are kept", old, "npm", 10, 0, false, opts, ""},
+		{"unknown downloads are pruned when included", old, "npm", 10, 0, false, unknownOpts, "older_than_180_days, beyond_last_5, downloads_unknown"},
+		{"tagged version with unknown downloads is kept when included", tagged, "npm", 10, 0, false, unknownOpts, ""},
+		{"old version", old, "npm", 1, 0, true, opts, "older_than_180_days"},
+		{"version beyond keep last", recent, "npm", 5, 0, true, opts, "beyond_last_5"},
+		{"old version beyond keep last", old, "npm", 7, 0, true, opts, "older_than_180_days, beyond_last_5"},
+		{"recent version within keep last", recent, "npm", 4, 0, true, opts, ""},
+		{"age limit disabled", old, "npm", 1, 0, true, pruneOptions{keepLast: 5}, ""},
+		{"untagged container version is kept when unknown downloads are included", old, "container", 10, 0, false, unknownOpts, ""},
+		{"untagged container version is kept", old, "container", 10, 0, false, opts, ""},
+		{"keep last disabled", recent, "npm", 10, 0, true, pruneOptions{cutoff: opts.cutoff, olderThanDays: 180}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := pruneReason(test.version, test.packageType, test.position, test.downloads, test.downloadsKnown, test.opts)
+			if got != test.want {
+				t.Errorf("pruneReason() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
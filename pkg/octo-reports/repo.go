@@ -6,22 +6,95 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/shurcooL/githubv4"
 )
 
 type Repo struct {
-	Name       string
-	Visibility string
-	IsArchived bool
-	IsFork     bool
-	ID         string
-	PushedAt   time.Time
-	CreatedAt  time.Time
-	Owner      string
-	Topics     []string
-	Teams      []Team
+	Name          string
+	Visibility    string
+	IsArchived    bool
+	IsFork        bool
+	ID            string
+	PushedAt      time.Time
+	CreatedAt     time.Time
+	Owner         string
+	Topics        []string
+	Teams         []Team
+	DefaultBranch string
+	Protection    *BranchProtection
+
+	HasVulnerabilityAlertsEnabled bool
+
+	PrimaryLanguage  string
+	Languages        []string
+	DiskUsage        int
+	License          string
+	Description      string
+	HomepageURL      string
+	IsTemplate       bool
+	IsMirror         bool
+	IsEmpty          bool
+	OpenIssues       int
+	OpenPullRequests int
+}
+
+// RepoField is an optional repository field that can be added to the repo report.
+type RepoField struct {
+	Name     string
+	Variable string
+	Value    func(repo *Repo) string
+}
+
+// repoFields lists the optional fields in the order they are written to the repo report.
+var repoFields = []RepoField{
+	{"default_branch", "withDefaultBranch", func(r *Repo) string { return r.DefaultBranch }},
+	{"language", "withLanguage", func(r *Repo) string { return r.PrimaryLanguage }},
+	{"languages", "withLanguages", func(r *Repo) string { return fmt.Sprintf("%v", r.Languages) }},
+	{"disk_usage_kb", "withDiskUsage", func(r *Repo) string { return fmt.Sprintf("%d", r.DiskUsage) }},
+	{"license", "withLicense", func(r *Repo) string { return r.License }},
+	{"description", "withDescription", func(r *Repo) string { return r.Description }},
+	{"homepage", "withHomepage", func(r *Repo) string { return r.HomepageURL }},
+	{"is_template", "withTemplate", func(r *Repo) string { return fmt.Sprintf("%t", r.IsTemplate) }},
+	{"is_mirror", "withMirror", func(r *Repo) string { return fmt.Sprintf("%t", r.IsMirror) }},
+	{"is_empty", "withEmpty", func(r *Repo) string { return fmt.Sprintf("%t", r.IsEmpty) }},
+	{"open_issues", "withOpenIssues", func(r *Repo) string { return fmt.Sprintf("%d", r.OpenIssues) }},
+	{"open_prs", "withOpenPullRequests", func(r *Repo) string { return fmt.Sprintf("%d", r.OpenPullRequests) }},
+}
+
+// ParseRepoFields validates a comma separated list of optional repo report fields. "all"
+// selects every field.
+func ParseRepoFields(list string) ([]string, error) {
+	fields := []string{}
+	if list == "" {
+		return fields, nil
+	}
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			fields = []string{}
+			for _, field := range repoFields {
+				fields = append(fields, field.Name)
+			}
+			return fields, nil
+		}
+
+		found := false
+		for _, field := range repoFields {
+			if field.Name == name {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		fields = append(fields, name)
+	}
+
+	return fields, nil
 }
 
 type Collaborator struct {
@@ -33,19 +106,41 @@ type Collaborator struct {
 }
 
 type Topics struct {
-	Nodes []struct {
+	PageInfo PageInfo
+	Nodes    []struct {
 		Topic struct {
 			Name string
 		}
 	}
 }
 
+type Languages struct {
+	PageInfo  PageInfo
+	TotalSize int
+	Edges     []struct {
+		Size int
+		Node struct {
+			Name string
+		}
+	}
+}
+
 func getOrgRepos(orgName string, getTeams bool, client *githubv4.Client) ([]*Repo, error) {
+	return getOrgReposWithFields(orgName, getTeams, nil, client)
+}
+
+// getOrgReposWithFields fetches the repositories of an organization along with the optional
+// repoFields named in fields. Fields that are not requested are skipped by the query, so
+// they cost nothing.
+func getOrgReposWithFields(orgName string, getTeams bool, fields []string, client *githubv4.Client) ([]*Repo, error) {
 
 	variables := map[string]interface{}{
 		"orgName": githubv4.String(orgName),
 		"cursor":  (*githubv4.String)(nil),
 	}
+	for _, field := range repoFields {
+		variables[field.Variable] = githubv4.Boolean(contains(fields, field.Name))
+	}
 
 	var query struct {
 		Organization struct {
@@ -55,23 +150,40 @@ func getOrgRepos(orgName string, getTeams bool, client *githubv4.Client) ([]*Rep
 					HasNextPage bool
 				}
 				Nodes []struct {
-					Name             string
-					Visibility       string
-					IsArchived       bool
-					IsFork           bool
-					ID               string
-					PushedAt         time.Time
-					CreatedAt        time.Time
-					RepositoryTopics struct {
-						Nodes []struct {
-							Topic struct {
-								Name string
-							}
-						}
-					} `graphql:"repositoryTopics(first: 100)"`
-					Owner struct {
+					Name                          string
+					Visibility                    string
+					IsArchived                    bool
+					IsFork                        bool
+					ID                            string
+					PushedAt                      time.Time
+					HasVulnerabilityAlertsEnabled bool
+					CreatedAt                     time.Time
+					RepositoryTopics              Topics `graphql:"repositoryTopics(first: 100)"`
+					Owner                         struct {
 						Login string
 					}
+					DefaultBranchRef struct {
+						Name string
+					} `graphql:"defaultBranchRef @include(if: $withDefaultBranch)"`
+					PrimaryLanguage struct {
+						Name string
+					} `graphql:"primaryLanguage @include(if: $withLanguage)"`
+					Languages   Languages `graphql:"languages(first: 100, orderBy: {field: SIZE, direction: DESC}) @include(if: $withLanguages)"`
+					DiskUsage   int       `graphql:"diskUsage @include(if: $withDiskUsage)"`
+					LicenseInfo struct {
+						SpdxId string
+					} `graphql:"licenseInfo @include(if: $withLicense)"`
+					Description string `graphql:"description @include(if: $withDescription)"`
+					HomepageUrl string `graphql:"homepageUrl @include(if: $withHomepage)"`
+					IsTemplate  bool   `graphql:"isTemplate @include(if: $withTemplate)"`
+					IsMirror    bool   `graphql:"isMirror @include(if: $withMirror)"`
+					IsEmpty     bool   `graphql:"isEmpty @include(if: $withEmpty)"`
+					Issues      struct {
+						TotalCount int
+					} `graphql:"issues(states: OPEN) @include(if: $withOpenIssues)"`
+					PullRequests struct {
+						TotalCount int
+					} `graphql:"pullRequests(states: OPEN) @include(if: $withOpenPullRequests)"`
 				}
 			} `graphql:"repositories(first: 100, after: $cursor)"`
 		} `graphql:"organization(login: $orgName)"`
@@ -95,45 +207,56 @@ func getOrgRepos(orgName string, getTeams bool, client *githubv4.Client) ([]*Rep
 
 		for _, repo := range query.Organization.Repositories.Nodes {
 
-			if getTeams {
-
-				teams, _ := getTeamsRoleForRepo(orgName, repo.Name, *client)
-
-				topics := []string{}
-				for _, t := range repo.RepositoryTopics.Nodes {
-					topics = append(topics, t.Topic.Name)
-				}
+			topics := []string{}
+			for _, t := range repo.RepositoryTopics.Nodes {
+				topics = append(topics, t.Topic.Name)
+			}
+			moreTopics, err := getRemainingTopics(repo.ID, repo.RepositoryTopics.PageInfo, client)
+			if err != nil {
+				panic(err)
+			}
+			topics = append(topics, moreTopics...)
 
-				allRepos = append(allRepos, &Repo{
-					Name:       repo.Name,
-					Visibility: repo.Visibility,
-					IsArchived: repo.IsArchived,
-					IsFork:     repo.IsFork,
-					ID:         repo.ID,
-					PushedAt:   repo.PushedAt,
-					CreatedAt:  repo.CreatedAt,
-					Owner:      repo.Owner.Login,
-					Topics:     topics,
-					Teams:      teams,
-				})
-			} else {
-				topics := []string{}
-				for _, t := range repo.RepositoryTopics.Nodes {
-					topics = append(topics, t.Topic.Name)
-				}
+			languages := []string{}
+			for _, l := range repo.Languages.Edges {
+				languages = append(languages, fmt.Sprintf("%s:%.1f%%", l.Node.Name, float64(l.Size)*100/float64(repo.Languages.TotalSize)))
+			}
+			moreLanguages, err := getRemainingLanguages(repo.ID, repo.Languages.TotalSize, repo.Languages.PageInfo, client)
+			if err != nil {
+				panic(err)
+			}
+			languages = append(languages, moreLanguages...)
+
+			r := &Repo{
+				Name:                          repo.Name,
+				Visibility:                    repo.Visibility,
+				IsArchived:                    repo.IsArchived,
+				IsFork:                        repo.IsFork,
+				ID:                            repo.ID,
+				PushedAt:                      repo.PushedAt,
+				CreatedAt:                     repo.CreatedAt,
+				Owner:                         repo.Owner.Login,
+				Topics:                        topics,
+				HasVulnerabilityAlertsEnabled: repo.HasVulnerabilityAlertsEnabled,
+				DefaultBranch:                 repo.DefaultBranchRef.Name,
+				PrimaryLanguage:               repo.PrimaryLanguage.Name,
+				Languages:                     languages,
+				DiskUsage:                     repo.DiskUsage,
+				License:                       repo.LicenseInfo.SpdxId,
+				Description:                   repo.Description,
+				HomepageURL:                   repo.HomepageUrl,
+				IsTemplate:                    repo.IsTemplate,
+				IsMirror:                      repo.IsMirror,
+				IsEmpty:                       repo.IsEmpty,
+				OpenIssues:                    repo.Issues.TotalCount,
+				OpenPullRequests:              repo.PullRequests.TotalCount,
+			}
 
-				allRepos = append(allRepos, &Repo{
-					Name:       repo.Name,
-					Visibility: repo.Visibility,
-					IsArchived: repo.IsArchived,
-					IsFork:     repo.IsFork,
-					ID:         repo.ID,
-					PushedAt:   repo.PushedAt,
-					CreatedAt:  repo.CreatedAt,
-					Owner:      repo.Owner.Login,
-					Topics:     topics,
-				})
+			if getTeams {
+				r.Teams, _ = getTeamsRoleForRepo(orgName, repo.Name, *client)
 			}
+
+			allRepos = append(allRepos, r)
 		}
 
 		if !query.Organization.Repositories.PageInfo.HasNextPage {
@@ -149,6 +272,75 @@ func getOrgRepos(orgName string, getTeams bool, client *githubv4.Client) ([]*Rep
 	return allRepos, nil
 }
 
+// getRemainingTopics fetches the topics past the first page of a repository's topics.
+func getRemainingTopics(repoID string, pageInfo PageInfo, client *githubv4.Client) ([]string, error) {
+
+	type topicsQuery struct {
+		Node struct {
+			Repository struct {
+				RepositoryTopics Topics `graphql:"repositoryTopics(first: 100, after: $cursor)"`
+			} `graphql:"... on Repository"`
+		} `graphql:"node(id: $id)"`
+	}
+
+	variables := map[string]interface{}{
+		"id": githubv4.ID(repoID),
+	}
+
+	return fetchNestedPages(client, variables, pageInfo, func(query *topicsQuery) ([]string, PageInfo) {
+		topics := []string{}
+		for _, t := range query.Node.Repository.RepositoryTopics.Nodes {
+			topics = append(topics, t.Topic.Name)
+		}
+		return topics, query.Node.Repository.RepositoryTopics.PageInfo
+	})
+}
+
+// getRemainingLanguages fetches the languages past the first page of a repository's languages,
+// formatted as a share of totalSize.
+func getRemainingLanguages(repoID string, totalSize int, pageInfo PageInfo, client *githubv4.Client) ([]string, error) {
+
+	type languagesQuery struct {
+		Node struct {
+			Repository struct {
+				Languages Languages `graphql:"languages(first: 100, after: $cursor, orderBy: {field: SIZE, direction: DESC})"`
+			} `graphql:"... on Repository"`
+		} `graphql:"node(id: $id)"`
+	}
+
+	variables := map[string]interface{}{
+		"id": githubv4.ID(repoID),
+	}
+
+	return fetchNestedPages(client, variables, pageInfo, func(query *languagesQuery) ([]string, PageInfo) {
+		languages := []string{}
+		for _, l := range query.Node.Repository.Languages.Edges {
+			languages = append(languages, fmt.Sprintf("%s:%.1f%%", l.Node.Name, float64(l.Size)*100/float64(totalSize)))
+		}
+		return languages, query.Node.Repository.Languages.PageInfo
+	})
+}
+
+type TeamRepositoryEdge struct {
+	Permission string
+	Node       struct {
+		Name string
+	}
+}
+
+type TeamRepositories struct {
+	PageInfo PageInfo
+	Edges    []TeamRepositoryEdge
+}
+
+type teamRepositoriesQuery struct {
+	Node struct {
+		Team struct {
+			Repositories TeamRepositories `graphql:"repositories(first: 100, after: $cursor, query: $repoName)"`
+		} `graphql:"... on Team"`
+	} `graphql:"node(id: $id)"`
+}
+
 func getTeamsRoleForRepo(orgName, repoName string, client githubv4.Client) ([]Team, error) {
 
 	variables := map[string]interface{}{
@@ -165,15 +357,9 @@ func getTeamsRoleForRepo(orgName, repoName string, client githubv4.Client) ([]Te
 					HasNextPage bool
 				}
 				Nodes []struct {
+					ID           string
 					Slug         string
-					Repositories struct {
-						Edges []struct {
-							Permission string
-							Node       struct {
-								Name string
-							}
-						}
-					} `graphql:"repositories(first: 1, query: $repoName)"`
+					Repositories TeamRepositories `graphql:"repositories(first: 10, query: $repoName)"`
 				}
 			} `graphql:"teams(first: 100, after: $cursor)"`
 		} `graphql:"organization(login: $orgName)"`
@@ -197,12 +383,26 @@ func getTeamsRoleForRepo(orgName, repoName string, client githubv4.Client) ([]Te
 
 		for _, team := range query.Organization.Teams.Nodes {
 
-			if len(team.Repositories.Edges) > 0 {
-
-				allTeams = append(allTeams, Team{
-					Name: team.Slug,
-					Role: team.Repositories.Edges[0].Permission,
-				})
+			// the query matches repository names by substring, so the exact match may be on a later page
+			edges := team.Repositories.Edges
+			moreEdges, err := fetchNestedPages(&client, map[string]interface{}{
+				"id":       githubv4.ID(team.ID),
+				"repoName": githubv4.String(repoName),
+			}, team.Repositories.PageInfo, func(query *teamRepositoriesQuery) ([]TeamRepositoryEdge, PageInfo) {
+				return query.Node.Team.Repositories.Edges, query.Node.Team.Repositories.PageInfo
+			})
+			if err != nil {
+				panic(err)
+			}
+			edges = append(edges, moreEdges...)
+
+			for _, edge := range edges {
+				if edge.Node.Name == repoName {
+					allTeams = append(allTeams, Team{
+						Name: team.Slug,
+						Role: edge.Permission,
+					})
+				}
 			}
 		}
 
@@ -218,7 +418,45 @@ func getTeamsRoleForRepo(orgName, repoName string, client githubv4.Client) ([]Te
 	return allTeams, nil
 }
 
-func GenerateRepoReport(enterpriseSlug string, client *githubv4.Client) error {
+// repoReportHeader returns the repo report columns, followed by the selected optional fields.
+func repoReportHeader(fields []string) []string {
+	header := []string{"id", "owner", "name", "visibility", "archived", "is_fork", "created_at", "pushed_at", "teams", "topics"}
+	for _, field := range repoFields {
+		if contains(fields, field.Name) {
+			header = append(header, field.Name)
+		}
+	}
+	return header
+}
+
+// repoReportRecord returns the repo report row of a repository, matching repoReportHeader.
+func repoReportRecord(repo *Repo, fields []string) []string {
+	var teams []string
+	for _, team := range repo.Teams {
+		teams = append(teams, team.Name+":"+team.Role)
+	}
+
+	record := []string{
+		repo.ID,
+		string(repo.Owner),
+		string(repo.Name),
+		string(repo.Visibility),
+		fmt.Sprintf("%t", repo.IsArchived),
+		fmt.Sprintf("%t", repo.IsFork),
+		repo.CreatedAt.Format(time.RFC3339),
+		repo.PushedAt.Format(time.RFC3339),
+		fmt.Sprintf("%v", teams),
+		fmt.Sprintf("%v", repo.Topics),
+	}
+	for _, field := range repoFields {
+		if contains(fields, field.Name) {
+			record = append(record, field.Value(repo))
+		}
+	}
+	return record
+}
+
+func GenerateRepoReport(enterpriseSlug string, fields []string, client *githubv4.Client) error {
 	file, err := os.Create("repos.csv")
 	if err != nil {
 		fmt.Println("Error creating the CSV file:", err)
@@ -229,9 +467,7 @@ func GenerateRepoReport(enterpriseSlug string, client *githubv4.Client) error {
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	header := []string{"id", "owner", "name", "visibility", "archived", "is_fork", "created_at", "pushed_at", "teams", "topics"}
-
-	err = writer.Write(header)
+	err = writer.Write(repoReportHeader(fields))
 	if err != nil {
 		fmt.Println("Error writing the header row:", err)
 		return nil
@@ -240,31 +476,13 @@ func GenerateRepoReport(enterpriseSlug string, client *githubv4.Client) error {
 	orgs, _ := getEnterpriseOrgs(enterpriseSlug, client)
 	for _, org := range orgs {
 
-		repos, err := getOrgRepos(string(org.Login), true, client)
+		repos, err := getOrgReposWithFields(string(org.Login), true, fields, client)
 		if err != nil {
 			log.Fatal(err)
 		}
 
 		for _, repo := range repos {
-			var teams []string
-			for _, team := range repo.Teams {
-				teams = append(teams, team.Name+":"+team.Role)
-			}
-
-			record := []string{
-				repo.ID,
-				string(repo.Owner),
-				string(repo.Name),
-				string(repo.Visibility),
-				fmt.Sprintf("%t", repo.IsArchived),
-				fmt.Sprintf("%t", repo.IsFork),
-				repo.CreatedAt.Format(time.RFC3339),
-				repo.PushedAt.Format(time.RFC3339),
-				fmt.Sprintf("%v", teams),
-				fmt.Sprintf("%v", repo.Topics),
-			}
-
-			err := writer.Write(record)
+			err := writer.Write(repoReportRecord(repo, fields))
 			if err != nil {
 				fmt.Println("Error writing the record:", err)
 			}
@@ -276,12 +494,13 @@ func GenerateRepoReport(enterpriseSlug string, client *githubv4.Client) error {
 	return nil
 }
 
-func getRepoCollaborators(orgName, repoName string, client *githubv4.Client) ([]*Collaborator, error) {
+func getRepoCollaborators(orgName, repoName string, affiliation githubv4.CollaboratorAffiliation, client *githubv4.Client) ([]*Collaborator, error) {
 
 	variables := map[string]interface{}{
-		"orgName":  githubv4.String(orgName),
-		"repoName": githubv4.String(repoName),
-		"cursor":   (*githubv4.String)(nil),
+		"orgName":     githubv4.String(orgName),
+		"repoName":    githubv4.String(repoName),
+		"affiliation": affiliation,
+		"cursor":      (*githubv4.String)(nil),
 	}
 
 	var query struct {
@@ -301,7 +520,7 @@ func getRepoCollaborators(orgName, repoName string, client *githubv4.Client) ([]
 							DatabaseID uint64
 						}
 					}
-				} `graphql:"collaborators(affiliation: ALL, first: 100, after: $cursor)"`
+				} `graphql:"collaborators(affiliation: $affiliation, first: 100, after: $cursor)"`
 			} `graphql:"repository(name: $repoName)"`
 		} `graphql:"organization(login: $orgName)"`
 		RateLimit RateLimit
@@ -370,7 +589,7 @@ func GenerateCollaboratorReport(orgName string, client *githubv4.Client) error {
 	}
 
 	for _, repo := range repos {
-		collaborators, err := getRepoCollaborators(orgName, string(repo.Name), client)
+		collaborators, err := getRepoCollaborators(orgName, string(repo.Name), githubv4.CollaboratorAffiliationAll, client)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -0,0 +1,356 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+// SecretEntry is a secret or variable found in an organization, repository or environment. Only
+// names and timestamps are kept; values are never read.
+type SecretEntry struct {
+	Owner       string
+	Repo        string
+	Environment string
+	Type        string
+	Kind        string
+	Name        string
+	Visibility  string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// listSecrets pages through one of the secret list endpoints.
+func listSecrets(list func(opts *github.ListOptions) (*github.Secrets, *github.Response, error)) ([]*github.Secret, error) {
+
+	opts := &github.ListOptions{PerPage: 100}
+
+	allSecrets := []*github.Secret{}
+	for {
+		secrets, resp, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		allSecrets = append(allSecrets, secrets.Secrets...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allSecrets, nil
+}
+
+// listVariables pages through one of the Actions variable list endpoints.
+func listVariables(list func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error)) ([]*github.ActionsVariable, error) {
+
+	opts := &github.ListOptions{PerPage: 100}
+
+	allVariables := []*github.ActionsVariable{}
+	for {
+		variables, resp, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		allVariables = append(allVariables, variables.Variables...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allVariables, nil
+}
+
+// listCodespacesSecrets lists the Codespaces secrets under path, which is orgs/<org> or
+// repos/<owner>/<repo>. go-github does not model Codespaces secrets, but the response has the
+// same shape as the other secret endpoints.
+func listCodespacesSecrets(path string, client *github.Client) ([]*github.Secret, error) {
+	return listSecrets(func(opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+		req, err := client.NewRequest("GET", fmt.Sprintf("%s/codespaces/secrets?per_page=%d&page=%d", path, opts.PerPage, opts.Page), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		secrets := &github.Secrets{}
+		resp, err := client.Do(context.Background(), req, secrets)
+		if err != nil {
+			return nil, nil, err
+		}
+		return secrets, resp, nil
+	})
+}
+
+// secretEntries converts secrets to entries for the inventory. Repository and environment
+// secrets have no visibility of their own, so scope is used instead.
+func secretEntries(owner, repo, environment, secretType, scope string, secrets []*github.Secret) []*SecretEntry {
+	entries := []*SecretEntry{}
+	for _, secret := range secrets {
+		visibility := secret.Visibility
+		if visibility == "" {
+			visibility = scope
+		}
+		entries = append(entries, &SecretEntry{
+			Owner:       owner,
+			Repo:        repo,
+			Environment: environment,
+			Type:        secretType,
+			Kind:        "secret",
+			Name:        secret.Name,
+			Visibility:  visibility,
+			CreatedAt:   secret.CreatedAt.Time,
+			UpdatedAt:   secret.UpdatedAt.Time,
+		})
+	}
+	return entries
+}
+
+// variableEntries converts Actions variables to entries for the inventory, dropping their values.
+func variableEntries(owner, repo, environment, scope string, variables []*github.ActionsVariable) []*SecretEntry {
+	entries := []*SecretEntry{}
+	for _, variable := range variables {
+		visibility := variable.GetVisibility()
+		if visibility == "" {
+			visibility = scope
+		}
+		entries = append(entries, &SecretEntry{
+			Owner:       owner,
+			Repo:        repo,
+			Environment: environment,
+			Type:        "actions",
+			Kind:        "variable",
+			Name:        variable.Name,
+			Visibility:  visibility,
+			CreatedAt:   variable.GetCreatedAt().Time,
+			UpdatedAt:   variable.GetUpdatedAt().Time,
+		})
+	}
+	return entries
+}
+
+func getOrgSecretEntries(orgName string, client *github.Client) []*SecretEntry {
+
+	entries := []*SecretEntry{}
+
+	actionsSecrets, err := listSecrets(func(opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+		return client.Actions.ListOrgSecrets(context.Background(), orgName, opts)
+	})
+	if err != nil {
+		log.Printf("Unable to fetch Actions secrets for %s: %v", orgName, err)
+	}
+	entries = append(entries, secretEntries(orgName, "", "", "actions", "", actionsSecrets)...)
+
+	dependabotSecrets, err := listSecrets(func(opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+		return client.Dependabot.ListOrgSecrets(context.Background(), orgName, opts)
+	})
+	if err != nil {
+		log.Printf("Unable to fetch Dependabot secrets for %s: %v", orgName, err)
+	}
+	entries = append(entries, secretEntries(orgName, "", "", "dependabot", "", dependabotSecrets)...)
+
+	codespacesSecrets, err := listCodespacesSecrets("orgs/"+orgName, client)
+	if err != nil && !isUnavailableError(err) {
+		log.Printf("Unable to fetch Codespaces secrets for %s: %v", orgName, err)
+	}
+	entries = append(entries, secretEntries(orgName, "", "", "codespaces", "", codespacesSecrets)...)
+
+	variables, err := listVariables(func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error) {
+		return client.Actions.ListOrgVariables(context.Background(), orgName, opts)
+	})
+	if err != nil {
+		log.Printf("Unable to fetch Actions variables for %s: %v", orgName, err)
+	}
+	entries = append(entries, variableEntries(orgName, "", "", "", variables)...)
+
+	return entries
+}
+
+func getRepoEnvironmentNames(orgName, repoName string, client *github.Client) ([]string, error) {
+
+	opts := &github.EnvironmentListOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	names := []string{}
+	for {
+		environments, resp, err := client.Repositories.ListEnvironments(context.Background(), orgName, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, environment := range environments.Environments {
+			names = append(names, environment.GetName())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	return names, nil
+}
+
+func getRepoSecretEntries(orgName, repoName string, client *github.Client) []*SecretEntry {
+
+	entries := []*SecretEntry{}
+
+	actionsSecrets, err := listSecrets(func(opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+		return client.Actions.ListRepoSecrets(context.Background(), orgName, repoName, opts)
+	})
+	if err != nil {
+		log.Printf("Unable to fetch Actions secrets for %s/%s: %v", orgName, repoName, err)
+	}
+	entries = append(entries, secretEntries(orgName, repoName, "", "actions", "repository", actionsSecrets)...)
+
+	dependabotSecrets, err := listSecrets(func(opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+		return client.Dependabot.ListRepoSecrets(context.Background(), orgName, repoName, opts)
+	})
+	if err != nil {
+		log.Printf("Unable to fetch Dependabot secrets for %s/%s: %v", orgName, repoName, err)
+	}
+	entries = append(entries, secretEntries(orgName, repoName, "", "dependabot", "repository", dependabotSecrets)...)
+
+	codespacesSecrets, err := listCodespacesSecrets(fmt.Sprintf("repos/%s/%s", orgName, repoName), client)
+	if err != nil && !isUnavailableError(err) {
+		log.Printf("Unable to fetch Codespaces secrets for %s/%s: %v", orgName, repoName, err)
+	}
+	entries = append(entries, secretEntries(orgName, repoName, "", "codespaces", "repository", codespacesSecrets)...)
+
+	variables, err := listVariables(func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error) {
+		return client.Actions.ListRepoVariables(context.Background(), orgName, repoName, opts)
+	})
+	if err != nil {
+		log.Printf("Unable to fetch Actions variables for %s/%s: %v", orgName, repoName, err)
+	}
+	entries = append(entries, variableEntries(orgName, repoName, "", "repository", variables)...)
+
+	environments, err := getRepoEnvironmentNames(orgName, repoName, client)
+	if err != nil {
+		log.Printf("Unable to fetch environments for %s/%s: %v", orgName, repoName, err)
+		return entries
+	}
+	if len(environments) == 0 {
+		return entries
+	}
+
+	// environment secrets are looked up by the repository's database ID
+	repo, _, err := client.Repositories.Get(context.Background(), orgName, repoName)
+	if err != nil {
+		log.Printf("Unable to fetch %s/%s: %v", orgName, repoName, err)
+		return entries
+	}
+
+	for _, name := range environments {
+
+		envSecrets, err := listSecrets(func(opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+			return client.Actions.ListEnvSecrets(context.Background(), int(repo.GetID()), url.PathEscape(name), opts)
+		})
+		if err != nil {
+			log.Printf("Unable to fetch secrets for the %s environment of %s/%s: %v", name, orgName, repoName, err)
+		}
+		entries = append(entries, secretEntries(orgName, repoName, name, "actions", "environment", envSecrets)...)
+
+		envVariables, err := listVariables(func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error) {
+			return client.Actions.ListEnvVariables(context.Background(), int(repo.GetID()), url.PathEscape(name), opts)
+		})
+		if err != nil {
+			log.Printf("Unable to fetch variables for the %s environment of %s/%s: %v", name, orgName, repoName, err)
+		}
+		entries = append(entries, variableEntries(orgName, repoName, name, "environment", envVariables)...)
+	}
+
+	return entries
+}
+
+// GenerateSecretsInventoryReport lists the names of every Actions, Dependabot and Codespaces
+// secret and Actions variable at the organization, repository and environment level. Secrets
+// that have not been updated in more than maxAgeDays days are flagged for rotation.
+func GenerateSecretsInventoryReport(enterpriseSlug string, maxAgeDays int, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("secrets-inventory.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"owner", "repo", "environment", "type", "kind", "name", "visibility", "created_at", "updated_at", "days_since_update", "needs_rotation"}
+	err = writer.Write(header)
+	if err != nil {
+		panic(err)
+	}
+
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -maxAgeDays)
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	total := 0
+	stale := 0
+	for _, org := range orgs {
+		orgName := string(org.Login)
+
+		start := time.Now()
+		log.Printf("Fetching secrets and variables for the %s organization.", orgName)
+
+		entries := getOrgSecretEntries(orgName, restClient)
+
+		repos, err := getOrgRepos(orgName, false, client)
+		if err != nil {
+			panic(err)
+		}
+		for _, repo := range repos {
+			if repo.IsArchived {
+				continue
+			}
+			entries = append(entries, getRepoSecretEntries(orgName, repo.Name, restClient)...)
+		}
+
+		for _, entry := range entries {
+			// variables are not credentials, so only secrets are due for rotation
+			needsRotation := entry.Kind == "secret" && entry.UpdatedAt.Before(cutoff)
+			if needsRotation {
+				stale++
+			}
+
+			record := []string{
+				entry.Owner,
+				entry.Repo,
+				entry.Environment,
+				entry.Type,
+				entry.Kind,
+				entry.Name,
+				entry.Visibility,
+				formatTime(entry.CreatedAt),
+				formatTime(entry.UpdatedAt),
+				fmt.Sprintf("%d", int(now.Sub(entry.UpdatedAt).Hours()/24)),
+				fmt.Sprintf("%t", needsRotation),
+			}
+			err = writer.Write(record)
+			if err != nil {
+				panic(err)
+			}
+		}
+		total += len(entries)
+
+		log.Printf("Found %d secrets and variables in %s in %v", len(entries), orgName, time.Since(start))
+	}
+
+	log.Printf("Found %d secrets not updated in more than %d days", stale, maxAgeDays)
+	log.Printf("Wrote %d records to secrets-inventory.csv", total)
+
+	return nil
+}
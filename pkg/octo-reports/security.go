@@ -0,0 +1,310 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+type SecuritySettings struct {
+	AdvancedSecurity             bool
+	CodeScanning                 bool
+	SecretScanning               bool
+	SecretScanningPushProtection bool
+	DependabotAlerts             bool
+	DependabotSecurityUpdates    bool
+	Committers                   int
+}
+
+// getOrgActiveCommitters returns the Advanced Security active committers of an organization with
+// every page of repositories. go-github's GetAdvancedSecurityActiveCommittersOrg only returns the
+// first page.
+func getOrgActiveCommitters(orgName string, client *github.Client) (*github.ActiveCommitters, error) {
+
+	allCommitters := &github.ActiveCommitters{}
+	page := 1
+	for {
+		req, err := client.NewRequest("GET", fmt.Sprintf("orgs/%s/settings/billing/advanced-security?per_page=100&page=%d", orgName, page), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		committers := &github.ActiveCommitters{}
+		resp, err := client.Do(context.Background(), req, committers)
+		if err != nil {
+			return nil, err
+		}
+		// the total is the same on every page
+		allCommitters.TotalAdvancedSecurityCommitters = committers.TotalAdvancedSecurityCommitters
+		allCommitters.Repositories = append(allCommitters.Repositories, committers.Repositories...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		page = resp.NextPage
+	}
+
+	return allCommitters, nil
+}
+
+// getOrgSecuritySettings returns the security and analysis settings of every repository in an
+// organization, keyed by repository name, and the organization's unique Advanced Security
+// committers. The REST API is used because these settings are not exposed through GraphQL.
+func getOrgSecuritySettings(orgName string, client *github.Client) (map[string]*SecuritySettings, int, error) {
+
+	type status struct {
+		Status string `json:"status"`
+	}
+
+	// go-github does not model dependabot_security_updates, so the response is decoded here
+	var repos []struct {
+		Name                string `json:"name"`
+		SecurityAndAnalysis struct {
+			AdvancedSecurity             status `json:"advanced_security"`
+			SecretScanning               status `json:"secret_scanning"`
+			SecretScanningPushProtection status `json:"secret_scanning_push_protection"`
+			DependabotSecurityUpdates    status `json:"dependabot_security_updates"`
+		} `json:"security_and_analysis"`
+	}
+
+	allSettings := map[string]*SecuritySettings{}
+	start := time.Now()
+	log.Printf("Fetching security settings for the %s organization.", orgName)
+	page := 1
+	for {
+		req, err := client.NewRequest("GET", fmt.Sprintf("orgs/%s/repos?per_page=100&page=%d", orgName, page), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		repos = nil
+		resp, err := client.Do(context.Background(), req, &repos)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, repo := range repos {
+			allSettings[repo.Name] = &SecuritySettings{
+				AdvancedSecurity:             repo.SecurityAndAnalysis.AdvancedSecurity.Status == "enabled",
+				SecretScanning:               repo.SecurityAndAnalysis.SecretScanning.Status == "enabled",
+				SecretScanningPushProtection: repo.SecurityAndAnalysis.SecretScanningPushProtection.Status == "enabled",
+				DependabotSecurityUpdates:    repo.SecurityAndAnalysis.DependabotSecurityUpdates.Status == "enabled",
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		page = resp.NextPage
+	}
+
+	total := 0
+	committers, err := getOrgActiveCommitters(orgName, client)
+	if err != nil {
+		log.Printf("Unable to fetch Advanced Security committers for %s: %v", orgName, err)
+	} else {
+		// committers active in several repositories are only counted once in the total
+		total = committers.TotalAdvancedSecurityCommitters
+		for _, repo := range committers.Repositories {
+			// committer names are returned as owner/repo
+			name := strings.TrimPrefix(repo.GetName(), orgName+"/")
+			if settings, ok := allSettings[name]; ok {
+				settings.Committers = repo.GetAdvancedSecurityCommitters()
+			}
+		}
+	}
+
+	log.Printf("Fetched security settings for %d repositories in %v", len(allSettings), time.Since(start))
+
+	return allSettings, total, nil
+}
+
+// hasCodeScanning reports whether a repository has any code scanning analyses.
+func hasCodeScanning(orgName, repoName string, client *github.Client) (bool, error) {
+	_, _, err := client.CodeScanning.ListAnalysesForRepo(context.Background(), orgName, repoName, &github.AnalysesListOptions{
+		ListOptions: github.ListOptions{PerPage: 1},
+	})
+	if err != nil {
+		// no analyses and Advanced Security being disabled are both reported as errors
+		if isDisabledError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// isDisabledError reports whether err is the response the REST API returns when a feature is not
+// enabled for a repository. That is a not found, or a forbidden response whose message says the
+// feature is disabled. Other forbidden responses are missing permissions and are not matched, so
+// that they are not mistaken for a disabled feature.
+func isDisabledError(err error) bool {
+	var errorResponse *github.ErrorResponse
+	if !errors.As(err, &errorResponse) {
+		return false
+	}
+	switch errorResponse.Response.StatusCode {
+	case http.StatusNotFound:
+		return true
+	case http.StatusForbidden:
+		message := strings.ToLower(errorResponse.Message)
+		return strings.Contains(message, "disabled") || strings.Contains(message, "not enabled") || strings.Contains(message, "must be enabled")
+	}
+	return false
+}
+
+// isUnavailableError reports whether err is a not found or forbidden response. It is used for
+// optional settings that are left blank when they cannot be read, whether the feature is missing
+// from the server or the token cannot see it.
+func isUnavailableError(err error) bool {
+	var errorResponse *github.ErrorResponse
+	if !errors.As(err, &errorResponse) {
+		return false
+	}
+	return errorResponse.Response.StatusCode == http.StatusNotFound || errorResponse.Response.StatusCode == http.StatusForbidden
+}
+
+func GenerateSecurityReport(enterpriseSlug string, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("security.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"id", "owner", "name", "archived", "advanced_security", "code_scanning", "secret_scanning", "push_protection", "dependabot_alerts", "dependabot_security_updates", "committers"}
+	err = writer.Write(header)
+	if err != nil {
+		panic(err)
+	}
+
+	summaryFile, err := os.Create("security-summary.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer summaryFile.Close()
+
+	summaryWriter := csv.NewWriter(summaryFile)
+	defer summaryWriter.Flush()
+
+	summaryHeader := []string{"org", "repos", "advanced_security_pct", "code_scanning_pct", "secret_scanning_pct", "push_protection_pct", "dependabot_alerts_pct", "dependabot_security_updates_pct", "committers"}
+	err = summaryWriter.Write(summaryHeader)
+	if err != nil {
+		panic(err)
+	}
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, org := range orgs {
+		repos, err := getOrgRepos(string(org.Login), false, client)
+		if err != nil {
+			panic(err)
+		}
+
+		allSettings, committers, err := getOrgSecuritySettings(string(org.Login), restClient)
+		if err != nil {
+			panic(err)
+		}
+
+		// percentages are calculated over active repositories only
+		var active, advancedSecurity, codeScanning, codeScanningChecked, secretScanning, pushProtection, dependabotAlerts, dependabotUpdates int
+		for _, repo := range repos {
+			settings, ok := allSettings[repo.Name]
+			if !ok {
+				settings = &SecuritySettings{}
+			}
+			settings.DependabotAlerts = repo.HasVulnerabilityAlertsEnabled
+
+			// code scanning is not checked for archived repositories, and is unknown when the
+			// analyses cannot be read, so neither counts towards the code scanning percentage
+			codeScanningCell := ""
+			if !repo.IsArchived {
+				settings.CodeScanning, err = hasCodeScanning(string(org.Login), repo.Name, restClient)
+				if err != nil {
+					log.Printf("Unable to fetch code scanning analyses for %s/%s: %v", org.Login, repo.Name, err)
+					codeScanningCell = "unknown"
+				} else {
+					codeScanningCell = fmt.Sprintf("%t", settings.CodeScanning)
+					codeScanningChecked++
+					codeScanning += boolToInt(settings.CodeScanning)
+				}
+
+				active++
+				advancedSecurity += boolToInt(settings.AdvancedSecurity)
+				secretScanning += boolToInt(settings.SecretScanning)
+				pushProtection += boolToInt(settings.SecretScanningPushProtection)
+				dependabotAlerts += boolToInt(settings.DependabotAlerts)
+				dependabotUpdates += boolToInt(settings.DependabotSecurityUpdates)
+			}
+
+			record := []string{
+				repo.ID,
+				repo.Owner,
+				repo.Name,
+				fmt.Sprintf("%t", repo.IsArchived),
+				fmt.Sprintf("%t", settings.AdvancedSecurity),
+				codeScanningCell,
+				fmt.Sprintf("%t", settings.SecretScanning),
+				fmt.Sprintf("%t", settings.SecretScanningPushProtection),
+				fmt.Sprintf("%t", settings.DependabotAlerts),
+				fmt.Sprintf("%t", settings.DependabotSecurityUpdates),
+				fmt.Sprintf("%d", settings.Committers),
+			}
+			err = writer.Write(record)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		summary := []string{
+			string(org.Login),
+			fmt.Sprintf("%d", active),
+			percentage(advancedSecurity, active),
+			percentage(codeScanning, codeScanningChecked),
+			percentage(secretScanning, active),
+			percentage(pushProtection, active),
+			percentage(dependabotAlerts, active),
+			percentage(dependabotUpdates, active),
+			fmt.Sprintf("%d", committers),
+		}
+		err = summaryWriter.Write(summary)
+		if err != nil {
+			panic(err)
+		}
+
+		log.Printf("Wrote %d records to security.csv", len(repos))
+	}
+
+	log.Printf("Wrote %d records to security-summary.csv", len(orgs))
+
+	return nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// percentage formats count as a percentage of total with one decimal place.
+func percentage(count, total int) string {
+	if total == 0 {
+		return "0.0"
+	}
+	return fmt.Sprintf("%.1f", float64(count)*100/float64(total))
+}
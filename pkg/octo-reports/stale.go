@@ -0,0 +1,266 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+type StaleRepo struct {
+	Repo         *Repo
+	Reasons      []string
+	DaysInactive int
+	LastPusher   string
+	OwningTeam   string
+}
+
+type RepoActivity struct {
+	LastCommitter   string
+	LastCommittedAt time.Time
+	ParentOwner     string
+	ParentBranch    string
+}
+
+// getRepoActivity returns the author of the latest commit on the default branch, which stands in
+// for the last pusher, and the parent a fork was created from.
+func getRepoActivity(orgName, repoName string, client *githubv4.Client) (*RepoActivity, error) {
+
+	variables := map[string]interface{}{
+		"orgName":  githubv4.String(orgName),
+		"repoName": githubv4.String(repoName),
+	}
+
+	var query struct {
+		Repository struct {
+			DefaultBranchRef struct {
+				Target struct {
+					Commit struct {
+						CommittedDate time.Time
+						Author        struct {
+							Name string
+							User struct {
+								Login string
+							}
+						}
+					} `graphql:"... on Commit"`
+				}
+			}
+			Parent struct {
+				Owner struct {
+					Login string
+				}
+				DefaultBranchRef struct {
+					Name string
+				}
+			}
+		} `graphql:"repository(owner: $orgName, name: $repoName)"`
+		RateLimit RateLimit
+	}
+
+	err := client.Query(context.Background(), &query, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	// check rate limit
+	if query.RateLimit.Remaining < 100 {
+		log.Printf("Rate limit: %d/%d, resets at: %s", query.RateLimit.Remaining, query.RateLimit.Limit, query.RateLimit.ResetAt)
+		time.Sleep(time.Until(query.RateLimit.ResetAt.Time))
+	}
+
+	commit := query.Repository.DefaultBranchRef.Target.Commit
+	committer := commit.Author.User.Login
+	if committer == "" {
+		committer = commit.Author.Name
+	}
+
+	return &RepoActivity{
+		LastCommitter:   committer,
+		LastCommittedAt: commit.CommittedDate,
+		ParentOwner:     query.Repository.Parent.Owner.Login,
+		ParentBranch:    query.Repository.Parent.DefaultBranchRef.Name,
+	}, nil
+}
+
+// forkHasDiverged reports whether a fork has commits that are not in its parent.
+func forkHasDiverged(repo *Repo, activity *RepoActivity, client *github.Client) (bool, error) {
+	base := activity.ParentOwner + ":" + activity.ParentBranch
+	head := repo.Owner + ":" + repo.DefaultBranch
+
+	comparison, _, err := client.Repositories.CompareCommits(context.Background(), repo.Owner, repo.Name, base, head, nil)
+	if err != nil {
+		return false, err
+	}
+
+	return comparison.GetAheadBy() > 0, nil
+}
+
+// owningTeam picks the team with the highest permission on the repository.
+func owningTeam(teams []Team) string {
+	owner := ""
+	rank := 0
+	for _, team := range teams {
+		if permissionRank(team.Role) > rank {
+			owner = team.Name
+			rank = permissionRank(team.Role)
+		}
+	}
+	return owner
+}
+
+// GenerateStaleReposReport finds repositories with no push in the last days days and no open pull
+// requests, as well as empty repositories and forks that never diverged from their parent. archive
+// can be "dry-run" to log the repositories that would be archived, or "apply" to archive them.
+func GenerateStaleReposReport(enterpriseSlug string, days int, archive string, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("stale-repos.csv")
+	if err != nil {
+		fmt.Println("Error creating the CSV file:", err)
+	}
+
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"id", "owner", "name", "visibility", "is_fork", "created_at", "pushed_at", "days_inactive", "reasons", "last_pusher", "owning_team", "archive_action"}
+
+	err = writer.Write(header)
+	if err != nil {
+		fmt.Println("Error writing the header row:", err)
+		return nil
+	}
+
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -days)
+
+	orgs, _ := getEnterpriseOrgs(enterpriseSlug, client)
+
+	candidates := []*StaleRepo{}
+	for _, org := range orgs {
+
+		repos, err := getOrgReposWithFields(string(org.Login), false, []string{"default_branch", "is_empty", "open_prs"}, client)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, repo := range repos {
+			if repo.IsArchived {
+				continue
+			}
+
+			// empty repositories and forks get the same grace period as the push cutoff, so that
+			// ones created recently are not archived before they have been used
+			established := repo.CreatedAt.Before(cutoff)
+
+			reasons := []string{}
+			if repo.PushedAt.Before(cutoff) && repo.OpenPullRequests == 0 {
+				reasons = append(reasons, "stale")
+			}
+			if repo.IsEmpty && established {
+				reasons = append(reasons, "empty")
+			}
+
+			// empty repositories have no commits or anything to compare
+			activity := &RepoActivity{}
+			if !repo.IsEmpty {
+				activity, err = getRepoActivity(repo.Owner, repo.Name, client)
+				if err != nil {
+					log.Printf("Unable to fetch the last commit for %s/%s: %v", repo.Owner, repo.Name, err)
+					activity = &RepoActivity{}
+				}
+			}
+
+			if repo.IsFork && established && !repo.IsEmpty && activity.ParentOwner != "" {
+				diverged, err := forkHasDiverged(repo, activity, restClient)
+				if err != nil {
+					log.Printf("Unable to compare %s/%s with its parent: %v", repo.Owner, repo.Name, err)
+				} else if !diverged {
+					reasons = append(reasons, "unchanged_fork")
+				}
+			}
+
+			if len(reasons) == 0 {
+				continue
+			}
+
+			// teams are only looked up for the repositories that are flagged
+			repo.Teams, err = getTeamsRoleForRepo(repo.Owner, repo.Name, *client)
+			if err != nil {
+				log.Printf("Unable to fetch the teams of %s/%s: %v", repo.Owner, repo.Name, err)
+			}
+
+			candidates = append(candidates, &StaleRepo{
+				Repo:         repo,
+				Reasons:      reasons,
+				DaysInactive: int(now.Sub(repo.PushedAt).Hours() / 24),
+				LastPusher:   activity.LastCommitter,
+				OwningTeam:   owningTeam(repo.Teams),
+			})
+		}
+	}
+
+	// longest inactive first
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].DaysInactive > candidates[j].DaysInactive
+	})
+
+	archived := 0
+	for _, candidate := range candidates {
+		repo := candidate.Repo
+
+		action := ""
+		switch archive {
+		case "dry-run":
+			action = "would_archive"
+			log.Printf("Would archive %s/%s", repo.Owner, repo.Name)
+		case "apply":
+			_, _, err := restClient.Repositories.Edit(context.Background(), repo.Owner, repo.Name, &github.Repository{
+				Archived: github.Bool(true),
+			})
+			if err != nil {
+				action = "archive_failed"
+				log.Printf("Unable to archive %s/%s: %v", repo.Owner, repo.Name, err)
+			} else {
+				action = "archived"
+				archived++
+				log.Printf("Archived %s/%s", repo.Owner, repo.Name)
+			}
+		}
+
+		record := []string{
+			repo.ID,
+			repo.Owner,
+			repo.Name,
+			repo.Visibility,
+			fmt.Sprintf("%t", repo.IsFork),
+			repo.CreatedAt.Format(time.RFC3339),
+			repo.PushedAt.Format(time.RFC3339),
+			fmt.Sprintf("%d", candidate.DaysInactive),
+			strings.Join(candidate.Reasons, ", "),
+			candidate.LastPusher,
+			candidate.OwningTeam,
+			action,
+		}
+
+		err := writer.Write(record)
+		if err != nil {
+			fmt.Println("Error writing the record:", err)
+		}
+	}
+
+	log.Printf("Found %d archival candidates", len(candidates))
+	if archive == "apply" {
+		log.Printf("Archived %d repositories", archived)
+	}
+	log.Printf("Wrote %d records to stale-repos.csv", len(candidates))
+
+	return nil
+}
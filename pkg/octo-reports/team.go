@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"time"
 
+	"github.com/google/go-github/v50/github"
 	"github.com/shurcooL/githubv4"
 )
 
@@ -17,9 +19,28 @@ type Team struct {
 	Slug        string
 	Description string
 	Role        string
+	Privacy     string
+	ParentTeam  string
+	ChildTeams  []string
+	IdPGroups   []string
 	Members     []*Member
 }
 
+type ChildTeams struct {
+	PageInfo PageInfo
+	Nodes    []struct {
+		Slug string
+	}
+}
+
+type childTeamsQuery struct {
+	Node struct {
+		Team struct {
+			ChildTeams ChildTeams `graphql:"childTeams(first: 100, after: $cursor, immediateOnly: true)"`
+		} `graphql:"... on Team"`
+	} `graphql:"node(id: $id)"`
+}
+
 func getOrgTeams(orgName string, client *githubv4.Client) ([]Team, error) {
 
 	variables := map[string]interface{}{
@@ -39,6 +60,11 @@ func getOrgTeams(orgName string, client *githubv4.Client) ([]Team, error) {
 					Name        string
 					Slug        string
 					Description string
+					Privacy     string
+					ParentTeam  struct {
+						Slug string
+					}
+					ChildTeams ChildTeams `graphql:"childTeams(first: 100, immediateOnly: true)"`
 				}
 			} `graphql:"teams(first: 100, after: $cursor)"`
 		} `graphql:"organization(login : $orgName)"`
@@ -62,16 +88,37 @@ func getOrgTeams(orgName string, client *githubv4.Client) ([]Team, error) {
 
 		for _, team := range query.Organization.Teams.Nodes {
 
-			allMembers, err := getTeamMembers(orgName, team.Slug, client)
+			allMembers, err := getTeamMembers(orgName, team.Slug, githubv4.TeamMembershipTypeAll, client)
+			if err != nil {
+				panic(err)
+			}
+
+			childTeams := []string{}
+			for _, child := range team.ChildTeams.Nodes {
+				childTeams = append(childTeams, child.Slug)
+			}
+			moreChildTeams, err := fetchNestedPages(client, map[string]interface{}{
+				"id": githubv4.ID(team.ID),
+			}, team.ChildTeams.PageInfo, func(query *childTeamsQuery) ([]string, PageInfo) {
+				slugs := []string{}
+				for _, child := range query.Node.Team.ChildTeams.Nodes {
+					slugs = append(slugs, child.Slug)
+				}
+				return slugs, query.Node.Team.ChildTeams.PageInfo
+			})
 			if err != nil {
 				panic(err)
 			}
+			childTeams = append(childTeams, moreChildTeams...)
 
 			allTeams = append(allTeams, Team{
 				ID:          team.ID,
 				Name:        team.Name,
 				Slug:        team.Slug,
 				Description: team.Description,
+				Privacy:     team.Privacy,
+				ParentTeam:  team.ParentTeam.Slug,
+				ChildTeams:  childTeams,
 				Members:     allMembers,
 			})
 		}
@@ -89,29 +136,33 @@ func getOrgTeams(orgName string, client *githubv4.Client) ([]Team, error) {
 	return allTeams, nil
 }
 
-func getTeamMembers(orgName, teamSlug string, client *githubv4.Client) ([]*Member, error) {
+// getTeamMembers returns the members of a team. With githubv4.TeamMembershipTypeAll the members of
+// its child teams are included, with githubv4.TeamMembershipTypeImmediate only its own members.
+func getTeamMembers(orgName, teamSlug string, membership githubv4.TeamMembershipType, client *githubv4.Client) ([]*Member, error) {
 
 	variables := map[string]interface{}{
-		"orgName":  githubv4.String(orgName),
-		"teamSlug": githubv4.String(teamSlug),
-		"cursor":   (*githubv4.String)(nil),
+		"orgName":    githubv4.String(orgName),
+		"teamSlug":   githubv4.String(teamSlug),
+		"membership": membership,
+		"cursor":     (*githubv4.String)(nil),
 	}
 
 	var query struct {
 		Organization struct {
-			Teams struct {
-				Nodes []struct {
-					Members struct {
-						PageInfo struct {
-							EndCursor   githubv4.String
-							HasNextPage bool
-						}
-						Nodes []struct {
+			Team struct {
+				Members struct {
+					PageInfo struct {
+						EndCursor   githubv4.String
+						HasNextPage bool
+					}
+					Edges []struct {
+						Role string
+						Node struct {
 							Login string
 						}
-					} `graphql:"members(first: 100, after: $cursor)"`
-				}
-			} `graphql:"teams(first: 1, query: $teamSlug)"`
+					}
+				} `graphql:"members(first: 100, after: $cursor, membership: $membership)"`
+			} `graphql:"team(slug: $teamSlug)"`
 		} `graphql:"organization(login: $orgName)"`
 		RateLimit RateLimit
 	}
@@ -131,17 +182,18 @@ func getTeamMembers(orgName, teamSlug string, client *githubv4.Client) ([]*Membe
 			time.Sleep(time.Minute)
 		}
 
-		for _, member := range query.Organization.Teams.Nodes[0].Members.Nodes {
+		for _, member := range query.Organization.Team.Members.Edges {
 			allMembers = append(allMembers, &Member{
-				Login: member.Login,
+				Login: member.Node.Login,
+				Role:  member.Role,
 			})
 		}
 
-		if !query.Organization.Teams.Nodes[0].Members.PageInfo.HasNextPage {
+		if !query.Organization.Team.Members.PageInfo.HasNextPage {
 			break
 		}
 
-		variables["cursor"] = githubv4.NewString(query.Organization.Teams.Nodes[0].Members.PageInfo.EndCursor)
+		variables["cursor"] = githubv4.NewString(query.Organization.Team.Members.PageInfo.EndCursor)
 
 	}
 
@@ -152,7 +204,84 @@ func getTeamMembers(orgName, teamSlug string, client *githubv4.Client) ([]*Membe
 
 }
 
-func GenerateTeamReport(enterpriseSlug string, client *githubv4.Client) error {
+// getTeamIdPGroups returns the identity provider groups a team is synced with. Team sync
+// groups are checked first, then the external groups used by enterprise managed users.
+func getTeamIdPGroups(orgName, teamSlug string, client *github.Client) ([]string, error) {
+
+	groups := []string{}
+
+	idpGroups, _, err := client.Teams.ListIDPGroupsForTeamBySlug(context.Background(), orgName, teamSlug)
+	if err != nil && !isUnavailableError(err) {
+		return nil, err
+	}
+	if err == nil {
+		for _, group := range idpGroups.Groups {
+			groups = append(groups, group.GetGroupName())
+		}
+	}
+	if len(groups) > 0 {
+		return groups, nil
+	}
+
+	externalGroups, _, err := client.Teams.ListExternalGroupsForTeamBySlug(context.Background(), orgName, teamSlug)
+	if err != nil {
+		if isUnavailableError(err) {
+			return groups, nil
+		}
+		return nil, err
+	}
+	for _, group := range externalGroups.Groups {
+		groups = append(groups, group.GetGroupName())
+	}
+
+	return groups, nil
+}
+
+// teamTree orders teams depth first from the root teams down, returning each team with
+// its path from the root, e.g. engineering/platform/sre.
+func teamTree(teams []Team) ([]Team, map[string]string) {
+
+	bySlug := map[string]Team{}
+	children := map[string][]string{}
+	roots := []string{}
+	for _, team := range teams {
+		bySlug[team.Slug] = team
+	}
+	for _, team := range teams {
+		// a parent outside the fetched set is treated as a root so the team is not lost
+		if _, ok := bySlug[team.ParentTeam]; team.ParentTeam == "" || !ok {
+			roots = append(roots, team.Slug)
+			continue
+		}
+		children[team.ParentTeam] = append(children[team.ParentTeam], team.Slug)
+	}
+
+	ordered := []Team{}
+	paths := map[string]string{}
+	var walk func(slug, parentPath string)
+	walk = func(slug, parentPath string) {
+		path := slug
+		if parentPath != "" {
+			path = parentPath + "/" + slug
+		}
+		paths[slug] = path
+		ordered = append(ordered, bySlug[slug])
+
+		sort.Strings(children[slug])
+		for _, child := range children[slug] {
+			walk(child, path)
+		}
+	}
+
+	sort.Strings(roots)
+	for _, root := range roots {
+		walk(root, "")
+	}
+
+	return ordered, paths
+}
+
+func GenerateTeamReport(enterpriseSlug string, client *githubv4.Client, restClient *github.Client) error {
 	file, err := os.Create("teams.csv")
 	if err != nil {
 		log.Println("Error creating the CSV file:", err)
@@ -163,7 +292,7 @@ func GenerateTeamReport(enterpriseSlug string, client *githubv4.Client) error {
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	header := []string{"id", "organization", "name", "slug", "description", "members"}
+	header := []string{"id", "organization", "name", "slug", "description", "privacy", "parent_team", "child_teams", "path", "idp_synced", "idp_groups", "members"}
 
 	err = writer.Write(header)
 	if err != nil {
@@ -182,12 +311,19 @@ func GenerateTeamReport(enterpriseSlug string, client *githubv4.Client) error {
 			log.Fatal(err)
 		}
 
+		teams, paths := teamTree(teams)
+
 		for _, team := range teams {
 
+			team.IdPGroups, err = getTeamIdPGroups(string(org.Login), team.Slug, restClient)
+			if err != nil {
+				log.Println("Error fetching the IdP groups:", err)
+			}
+
 			members := []string{}
 
 			for _, member := range team.Members {
-				members = append(members, member.Login)
+				members = append(members, member.Login+":"+member.Role)
 			}
 
 			record := []string{
@@ -196,6 +332,12 @@ func GenerateTeamReport(enterpriseSlug string, client *githubv4.Client) error {
 				team.Name,
 				team.Slug,
 				team.Description,
+				team.Privacy,
+				team.ParentTeam,
+				fmt.Sprintf("%v", team.ChildTeams),
+				paths[team.Slug],
+				fmt.Sprintf("%t", len(team.IdPGroups) > 0),
+				fmt.Sprintf("%v", team.IdPGroups),
 				fmt.Sprintf("%v", members),
 			}
 			err := writer.Write(record)
@@ -208,3 +350,199 @@ func GenerateTeamReport(enterpriseSlug string, client *githubv4.Client) error {
 
 	return nil
 }
+
+type RepoAccess struct {
+	Repo       string
+	Permission string
+	// Source is the team, or other grant, the permission comes from
+	Source    string
+	Inherited bool
+}
+
+func getTeamRepos(orgName, teamSlug string, client *githubv4.Client) ([]*RepoAccess, error) {
+
+	variables := map[string]interface{}{
+		"orgName":  githubv4.String(orgName),
+		"teamSlug": githubv4.String(teamSlug),
+		"cursor":   (*githubv4.String)(nil),
+	}
+
+	var query struct {
+		Organization struct {
+			Team struct {
+				Repositories struct {
+					PageInfo struct {
+						EndCursor   githubv4.String
+						HasNextPage bool
+					}
+					Edges []struct {
+						Permission string
+						Node       struct {
+							Name string
+						}
+					}
+				} `graphql:"repositories(first: 100, after: $cursor)"`
+			} `graphql:"team(slug: $teamSlug)"`
+		} `graphql:"organization(login: $orgName)"`
+		RateLimit RateLimit
+	}
+
+	allRepos := []*RepoAccess{}
+	startTime := time.Now()
+	log.Printf("Fetching all repositories for %s/%s", orgName, teamSlug)
+	for {
+		err := client.Query(context.Background(), &query, variables)
+		if err != nil {
+			panic(err)
+		}
+
+		// check rate limit
+		if query.RateLimit.Remaining < 100 {
+			log.Printf("Rate limit: %d/%d, resets at: %s", query.RateLimit.Remaining, query.RateLimit.Limit, query.RateLimit.ResetAt)
+			time.Sleep(time.Until(query.RateLimit.ResetAt.Time))
+		}
+
+		for _, edge := range query.Organization.Team.Repositories.Edges {
+			allRepos = append(allRepos, &RepoAccess{
+				Repo:       edge.Node.Name,
+				Permission: edge.Permission,
+				Source:     teamSlug,
+			})
+		}
+
+		if !query.Organization.Team.Repositories.PageInfo.HasNextPage {
+			break
+		}
+
+		variables["cursor"] = githubv4.NewString(query.Organization.Team.Repositories.PageInfo.EndCursor)
+	}
+
+	log.Printf("Found %d repositories for the %s/%s team.", len(allRepos), orgName, teamSlug)
+	log.Printf("Fetched all repositories in  %s.", time.Since(startTime))
+
+	return allRepos, nil
+}
+
+// getOrgTeamAccess returns the repositories every team in an organization can access, keyed by
+// team slug. Child teams inherit the access of their parent teams, so each team's direct grants
+// are combined with those of its ancestors, keeping the highest permission per repository.
+func getOrgTeamAccess(orgName string, teams []Team, client *githubv4.Client) (map[string][]*RepoAccess, error) {
+
+	bySlug := map[string]Team{}
+	direct := map[string][]*RepoAccess{}
+	for _, team := range teams {
+		bySlug[team.Slug] = team
+
+		repos, err := getTeamRepos(orgName, team.Slug, client)
+		if err != nil {
+			return nil, err
+		}
+		direct[team.Slug] = repos
+	}
+
+	access := map[string][]*RepoAccess{}
+	for _, team := range teams {
+		byRepo := map[string]*RepoAccess{}
+		repos := []string{}
+
+		// the team itself comes first so direct grants win ties with inherited ones
+		slug, inherited := team.Slug, false
+		for {
+			for _, grant := range direct[slug] {
+				current, ok := byRepo[grant.Repo]
+				if ok && permissionRank(current.Permission) >= permissionRank(grant.Permission) {
+					continue
+				}
+				if !ok {
+					repos = append(repos, grant.Repo)
+				}
+				byRepo[grant.Repo] = &RepoAccess{
+					Repo:       grant.Repo,
+					Permission: grant.Permission,
+					Source:     slug,
+					Inherited:  inherited,
+				}
+			}
+
+			parent := bySlug[slug].ParentTeam
+			if _, ok := bySlug[parent]; !ok {
+				break
+			}
+			slug, inherited = parent, true
+		}
+
+		sort.Strings(repos)
+		for _, repo := range repos {
+			access[team.Slug] = append(access[team.Slug], byRepo[repo])
+		}
+	}
+
+	return access, nil
+}
+
+func GenerateTeamAccessReport(enterpriseSlug string, client *githubv4.Client) error {
+	file, err := os.Create("team-access.csv")
+	if err != nil {
+		log.Println("Error creating the CSV file:", err)
+	}
+
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"organization", "team", "repository", "permission", "inherited", "inherited_from"}
+
+	err = writer.Write(header)
+	if err != nil {
+		log.Println("Error writing the header row:", err)
+		return nil
+	}
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	records := 0
+	for _, org := range orgs {
+		teams, err := getOrgTeams(string(org.Login), client)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		teams, _ = teamTree(teams)
+
+		access, err := getOrgTeamAccess(string(org.Login), teams, client)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, team := range teams {
+			for _, grant := range access[team.Slug] {
+				inheritedFrom := ""
+				if grant.Inherited {
+					inheritedFrom = grant.Source
+				}
+
+				record := []string{
+					string(org.Login),
+					team.Slug,
+					grant.Repo,
+					grant.Permission,
+					fmt.Sprintf("%t", grant.Inherited),
+					inheritedFrom,
+				}
+				err := writer.Write(record)
+				if err != nil {
+					log.Println("Error writing the record:", err)
+				}
+				records++
+			}
+		}
+	}
+
+	log.Printf("Wrote %d records to team-access.csv", records)
+
+	return nil
+}
@@ -0,0 +1,457 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+type UserActivity struct {
+	Login                  string
+	Name                   string
+	LastContribution       time.Time
+	LastCommitContribution time.Time
+	LastSignIn             time.Time
+}
+
+// LastActivity returns the most recent of the activity timestamps that were found for the user.
+func (a *UserActivity) LastActivity() time.Time {
+	last := a.LastContribution
+	for _, t := range []time.Time{a.LastCommitContribution, a.LastSignIn} {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+func getUserActivity(login string, from time.Time, client *githubv4.Client) (*UserActivity, error) {
+
+	variables := map[string]interface{}{
+		"login": githubv4.String(login),
+		"from":  githubv4.DateTime{Time: from},
+	}
+
+	var query struct {
+		User struct {
+			Login                   string
+			Name                    string
+			ContributionsCollection struct {
+				ContributionCalendar struct {
+					Weeks []struct {
+						ContributionDays []struct {
+							Date              string
+							ContributionCount int
+						}
+					}
+				}
+				CommitContributionsByRepository []struct {
+					Contributions struct {
+						Nodes []struct {
+							OccurredAt time.Time
+						}
+					} `graphql:"contributions(first: 1, orderBy: {field: OCCURRED_AT, direction: DESC})"`
+				} `graphql:"commitContributionsByRepository(maxRepositories: 100)"`
+			} `graphql:"contributionsCollection(from: $from)"`
+		} `graphql:"user(login: $login)"`
+		RateLimit RateLimit
+	}
+
+	err := client.Query(context.Background(), &query, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	// check rate limit
+	if query.RateLimit.Remaining < 100 {
+		log.Printf("Rate limit: %d/%d, resets at: %s", query.RateLimit.Remaining, query.RateLimit.Limit, query.RateLimit.ResetAt)
+		time.Sleep(time.Until(query.RateLimit.ResetAt.Time))
+	}
+
+	activity := &UserActivity{
+		Login: query.User.Login,
+		Name:  query.User.Name,
+	}
+
+	for _, week := range query.User.ContributionsCollection.ContributionCalendar.Weeks {
+		for _, day := range week.ContributionDays {
+			if day.ContributionCount == 0 {
+				continue
+			}
+			date, err := time.Parse("2006-01-02", day.Date)
+			if err != nil {
+				return nil, err
+			}
+			if date.After(activity.LastContribution) {
+				activity.LastContribution = date
+			}
+		}
+	}
+
+	for _, repo := range query.User.ContributionsCollection.CommitContributionsByRepository {
+		for _, contribution := range repo.Contributions.Nodes {
+			if contribution.OccurredAt.After(activity.LastCommitContribution) {
+				activity.LastCommitContribution = contribution.OccurredAt
+			}
+		}
+	}
+
+	return activity, nil
+}
+
+func getEnterpriseSignIns(enterpriseSlug string, since time.Time, client *github.Client) (map[string]time.Time, error) {
+
+	opts := &github.GetAuditLogOptions{
+		Phrase: github.String(fmt.Sprintf("action:user.login created:>=%s", since.Format("2006-01-02"))),
+		ListCursorOptions: github.ListCursorOptions{
+			PerPage: 100,
+		},
+	}
+
+	signIns := map[string]time.Time{}
+	start := time.Now()
+	log.Printf("Fetching sign-in events for the %s Enterprise.", enterpriseSlug)
+	for {
+		entries, resp, err := client.Enterprise.GetAuditLog(context.Background(), enterpriseSlug, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.Actor == nil || entry.Timestamp == nil {
+				continue
+			}
+			if entry.Timestamp.Time.After(signIns[*entry.Actor]) {
+				signIns[*entry.Actor] = entry.Timestamp.Time
+			}
+		}
+
+		if resp.After == "" {
+			break
+		}
+		opts.After = resp.After
+	}
+
+	log.Printf("Found sign-in events for %d users", len(signIns))
+	log.Printf("Fetched all sign-in events in %v", time.Since(start))
+
+	return signIns, nil
+}
+
+func GenerateDormantUsersReport(enterpriseSlug string, inactiveDays int, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("dormant-users.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"login", "name", "last_contribution", "last_commit_contribution", "last_sign_in", "last_activity", "days_inactive"}
+	err = writer.Write(header)
+	if err != nil {
+		panic(err)
+	}
+
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -inactiveDays)
+	// the contributions collection can span at most one year
+	from := now.AddDate(-1, 0, 0)
+
+	members, err := getEnterpriseMembers(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	// sign-in events are only available where the audit log exposes them, so a failure here is not fatal
+	signIns, err := getEnterpriseSignIns(enterpriseSlug, from, restClient)
+	if err != nil {
+		log.Printf("Unable to fetch sign-in events, continuing without them: %v", err)
+		signIns = map[string]time.Time{}
+	}
+
+	dormant := []*UserActivity{}
+	// members whose activity could not be fetched are still written out, so they are not missed
+	unknown := []*UserActivity{}
+	for _, member := range members {
+		activity, err := getUserActivity(member.Login, from, client)
+		if err != nil {
+			log.Printf("Unable to fetch activity for %s: %v", member.Login, err)
+			unknown = append(unknown, &UserActivity{
+				Login:      member.Login,
+				Name:       member.Name,
+				LastSignIn: signIns[member.Login],
+			})
+			continue
+		}
+		activity.Name = member.Name
+		activity.LastSignIn = signIns[member.Login]
+
+		if activity.LastActivity().Before(cutoff) {
+			dormant = append(dormant, activity)
+		}
+	}
+
+	// longest inactive first, users with no activity at all sort to the top
+	sort.Slice(dormant, func(i, j int) bool {
+		return dormant[i].LastActivity().Before(dormant[j].LastActivity())
+	})
+
+	for _, activity := range dormant {
+		daysInactive := "never"
+		if !activity.LastActivity().IsZero() {
+			daysInactive = fmt.Sprintf("%d", int(now.Sub(activity.LastActivity()).Hours()/24))
+		}
+
+		record := []string{
+			activity.Login,
+			activity.Name,
+			formatTime(activity.LastContribution),
+			formatTime(activity.LastCommitContribution),
+			formatTime(activity.LastSignIn),
+			formatTime(activity.LastActivity()),
+			daysInactive,
+		}
+		err = writer.Write(record)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	for _, activity := range unknown {
+		record := []string{
+			activity.Login,
+			activity.Name,
+			"unknown",
+			"unknown",
+			formatTime(activity.LastSignIn),
+			"unknown",
+			"unknown",
+		}
+		err = writer.Write(record)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	log.Printf("Found %d dormant users out of %d members, %d with unknown activity", len(dormant), len(members), len(unknown))
+	log.Printf("Wrote %d records to dormant-users.csv", len(dormant)+len(unknown))
+
+	return nil
+}
+
+// formatTime formats a timestamp for the reports, leaving it blank when no timestamp was found.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// ancestorTeams returns the given teams together with all of their parent teams.
+func ancestorTeams(teams []Team, allTeams []Team) []Team {
+
+	bySlug := map[string]Team{}
+	for _, team := range allTeams {
+		bySlug[team.Slug] = team
+	}
+
+	seen := map[string]bool{}
+	result := []Team{}
+	for _, team := range teams {
+		for slug := team.Slug; slug != "" && !seen[slug]; slug = bySlug[slug].ParentTeam {
+			if _, ok := bySlug[slug]; !ok {
+				break
+			}
+			seen[slug] = true
+			result = append(result, bySlug[slug])
+		}
+	}
+
+	return result
+}
+
+// userTeamGrants formats the repository access a user gets through their teams. A grant reaches
+// the user once per source team: when the user is a member of both a team and its ancestor, the
+// ancestor's grants are listed as direct grants of the ancestor and not again as inherited by the
+// child team.
+func userTeamGrants(orgName string, userTeams []Team, teamAccess map[string][]*RepoAccess) []string {
+
+	type grantLine struct {
+		key  string
+		line string
+	}
+
+	seen := map[string]bool{}
+	lines := []grantLine{}
+	// direct grants are taken first so that they win over the same grant inherited by a child team
+	for _, inherited := range []bool{false, true} {
+		for _, team := range userTeams {
+			for _, grant := range teamAccess[team.Slug] {
+				if grant.Inherited != inherited {
+					continue
+				}
+				key := grant.Repo + "\x00" + grant.Source
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				via := "via " + team.Slug
+				if grant.Inherited {
+					via += " (inherited from " + grant.Source + ")"
+				}
+				lines = append(lines, grantLine{grant.Repo + "\x00" + team.Slug, fmt.Sprintf("  %s/%s\t%s\t%s", orgName, grant.Repo, grant.Permission, via)})
+			}
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].key < lines[j].key
+	})
+
+	result := []string{}
+	for _, line := range lines {
+		result = append(result, line.line)
+	}
+
+	return result
+}
+
+// GenerateUserAccessReport prints everything a single user can access in the enterprise: their
+// enterprise role, org memberships, teams, direct repository grants and repositories reached
+// through teams. When orgName is set only that organization is searched.
+func GenerateUserAccessReport(enterpriseSlug, login, orgName string, client *githubv4.Client) error {
+
+	enterpriseRole := ""
+	admins, err := getEnterpriseAdmins(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+	for _, admin := range admins {
+		if strings.EqualFold(admin.Login, login) {
+			enterpriseRole = admin.Role
+		}
+	}
+
+	orgs := []*Org{{Login: githubv4.String(orgName)}}
+	if orgName == "" {
+		orgs, err = getEnterpriseOrgs(enterpriseSlug, client)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	orgRoles := []string{}
+	teamRoles := []string{}
+	directGrants := []string{}
+	teamGrants := []string{}
+
+	for _, org := range orgs {
+		members, err := getOrgMembersWithRole(string(org.Login), client)
+		if err != nil {
+			panic(err)
+		}
+		for _, member := range members {
+			if strings.EqualFold(member.Login, login) {
+				orgRoles = append(orgRoles, fmt.Sprintf("  %s\t%s", org.Login, member.Role))
+			}
+		}
+
+		allTeams, err := getOrgTeams(string(org.Login), client)
+		if err != nil {
+			panic(err)
+		}
+
+		// team members include the members of child teams, so the teams the user appears in are
+		// checked again for the user's own membership, or every ancestor of the user's teams
+		// would be reported as a team of theirs
+		userTeams := []Team{}
+		for _, team := range allTeams {
+			found := false
+			for _, member := range team.Members {
+				if strings.EqualFold(member.Login, login) {
+					found = true
+				}
+			}
+			if !found {
+				continue
+			}
+
+			immediateMembers, err := getTeamMembers(string(org.Login), team.Slug, githubv4.TeamMembershipTypeImmediate, client)
+			if err != nil {
+				panic(err)
+			}
+			for _, member := range immediateMembers {
+				if strings.EqualFold(member.Login, login) {
+					userTeams = append(userTeams, team)
+					teamRoles = append(teamRoles, fmt.Sprintf("  %s/%s\t%s", org.Login, team.Slug, member.Role))
+				}
+			}
+		}
+
+		// the parents of the user's teams are needed to resolve inherited access
+		teamAccess, err := getOrgTeamAccess(string(org.Login), ancestorTeams(userTeams, allTeams), client)
+		if err != nil {
+			panic(err)
+		}
+		teamGrants = append(teamGrants, userTeamGrants(string(org.Login), userTeams, teamAccess)...)
+
+		repos, err := getOrgRepos(string(org.Login), false, client)
+		if err != nil {
+			panic(err)
+		}
+		for _, repo := range repos {
+			collaborators, err := getRepoCollaborators(string(org.Login), repo.Name, githubv4.CollaboratorAffiliationDirect, client)
+			if err != nil {
+				panic(err)
+			}
+			for _, collaborator := range collaborators {
+				if strings.EqualFold(collaborator.Login, login) {
+					directGrants = append(directGrants, fmt.Sprintf("  %s/%s\t%s", org.Login, repo.Name, collaborator.Permission))
+				}
+			}
+		}
+	}
+
+	if enterpriseRole == "" && len(orgRoles) > 0 {
+		enterpriseRole = "MEMBER"
+	}
+	if enterpriseRole == "" {
+		enterpriseRole = "none"
+	}
+
+	fmt.Fprintf(w, "User:\t%s\n", login)
+	fmt.Fprintf(w, "Enterprise role:\t%s\n", enterpriseRole)
+
+	sections := []struct {
+		title string
+		lines []string
+	}{
+		{"Organizations", orgRoles},
+		{"Teams", teamRoles},
+		{"Direct repository access", directGrants},
+		{"Repository access through teams", teamGrants},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(w, "\n%s (%d):\n", section.title, len(section.lines))
+		for _, line := range section.lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	return nil
+}
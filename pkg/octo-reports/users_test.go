@@ -0,0 +1,60 @@
+package octoreports
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUserTeamGrants(t *testing.T) {
+	parent := Team{Slug: "platform"}
+	child := Team{Slug: "platform-sre", ParentTeam: "platform"}
+
+	teamAccess := map[string][]*RepoAccess{
+		"platform": {
+			{Repo: "api", Permission: "WRITE", Source: "platform"},
+		},
+		"platform-sre": {
+			{Repo: "api", Permission: "WRITE", Source: "platform", Inherited: true},
+			{Repo: "infra", Permission: "ADMIN", Source: "platform-sre"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		userTeams []Team
+		want      []string
+	}{
+		{
+			name:      "member of the child team only",
+			userTeams: []Team{child},
+			want: []string{
+				"  acme/api\tWRITE\tvia platform-sre (inherited from platform)",
+				"  acme/infra\tADMIN\tvia platform-sre",
+			},
+		},
+		{
+			name:      "member of the parent team only",
+			userTeams: []Team{parent},
+			want: []string{
+				"  acme/api\tWRITE\tvia platform",
+			},
+		},
+		{
+			name:      "member of both teams",
+			userTeams: []Team{child, parent},
+			want: []string{
+				"  acme/api\tWRITE\tvia platform",
+				"  acme/infra\tADMIN\tvia platform-sre",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := userTeamGrants("acme", test.userTeams, teamAccess)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("userTeamGrants() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package octoreports
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/shurcooL/githubv4"
+)
+
+var severities = []string{"critical", "high", "medium", "low"}
+
+// severityWeights are used to rank repositories by the risk of their open alerts.
+var severityWeights = map[string]int{
+	"critical": 10,
+	"high":     5,
+	"medium":   2,
+	"low":      1,
+}
+
+type AlertCounts struct {
+	Owner          string
+	Name           string
+	Dependabot     map[string]int
+	CodeScanning   map[string]int
+	SecretScanning int
+	OldestOpen     time.Time
+	// Unknown lists the alert sources that could not be read, whose counts are not known.
+	Unknown []string
+}
+
+// Total returns the number of open alerts across all sources.
+func (c *AlertCounts) Total() int {
+	total := c.SecretScanning
+	for _, severity := range severities {
+		total += c.Dependabot[severity] + c.CodeScanning[severity]
+	}
+	return total
+}
+
+// RiskScore weights the open alerts by severity. Exposed secrets are always weighted as critical.
+func (c *AlertCounts) RiskScore() int {
+	score := c.SecretScanning * severityWeights["critical"]
+	for _, severity := range severities {
+		score += (c.Dependabot[severity] + c.CodeScanning[severity]) * severityWeights[severity]
+	}
+	return score
+}
+
+func (c *AlertCounts) observe(createdAt *github.Timestamp) {
+	if createdAt == nil {
+		return
+	}
+	if c.OldestOpen.IsZero() || createdAt.Time.Before(c.OldestOpen) {
+		c.OldestOpen = createdAt.Time
+	}
+}
+
+// codeScanningSeverity maps a code scanning rule to the severity scale used by Dependabot.
+// Rules without a security severity, such as quality queries, fall back to their rule severity.
+func codeScanningSeverity(rule *github.Rule) string {
+	if level := rule.GetSecuritySeverityLevel(); level != "" {
+		return level
+	}
+	switch rule.GetSeverity() {
+	case "error":
+		return "high"
+	case "warning":
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// getRepoAlertCounts counts the open alerts of a repository. A source that is disabled counts as
+// no alerts, while one that cannot be read for another reason, such as a token without the
+// security_events scope, is logged and added to Unknown.
+func getRepoAlertCounts(orgName, repoName string, client *github.Client) *AlertCounts {
+
+	counts := &AlertCounts{
+		Owner:        orgName,
+		Name:         repoName,
+		Dependabot:   map[string]int{},
+		CodeScanning: map[string]int{},
+	}
+
+	dependabotOpts := &github.ListAlertsOptions{
+		State:             github.String("open"),
+		ListCursorOptions: github.ListCursorOptions{PerPage: 100},
+	}
+	for {
+		alerts, resp, err := client.Dependabot.ListRepoAlerts(context.Background(), orgName, repoName, dependabotOpts)
+		if err != nil {
+			if !isDisabledError(err) {
+				log.Printf("Unable to fetch Dependabot alerts for %s/%s: %v", orgName, repoName, err)
+				counts.Unknown = append(counts.Unknown, "dependabot")
+			}
+			break
+		}
+
+		for _, alert := range alerts {
+			severity := alert.GetSecurityVulnerability().GetSeverity()
+			if severity == "" {
+				severity = alert.GetSecurityAdvisory().GetSeverity()
+			}
+			counts.Dependabot[severity]++
+			counts.observe(alert.CreatedAt)
+		}
+
+		if resp.After == "" {
+			break
+		}
+		dependabotOpts.After = resp.After
+	}
+
+	codeScanningOpts := &github.AlertListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		alerts, resp, err := client.CodeScanning.ListAlertsForRepo(context.Background(), orgName, repoName, codeScanningOpts)
+		if err != nil {
+			if !isDisabledError(err) {
+				log.Printf("Unable to fetch code scanning alerts for %s/%s: %v", orgName, repoName, err)
+				counts.Unknown = append(counts.Unknown, "code_scanning")
+			}
+			break
+		}
+
+		for _, alert := range alerts {
+			counts.CodeScanning[codeScanningSeverity(alert.Rule)]++
+			counts.observe(alert.CreatedAt)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		codeScanningOpts.ListOptions.Page = resp.NextPage
+	}
+
+	secretScanningOpts := &github.SecretScanningAlertListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		alerts, resp, err := client.SecretScanning.ListAlertsForRepo(context.Background(), orgName, repoName, secretScanningOpts)
+		if err != nil {
+			if !isDisabledError(err) {
+				log.Printf("Unable to fetch secret scanning alerts for %s/%s: %v", orgName, repoName, err)
+				counts.Unknown = append(counts.Unknown, "secret_scanning")
+			}
+			break
+		}
+
+		for _, alert := range alerts {
+			counts.SecretScanning++
+			counts.observe(alert.CreatedAt)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		secretScanningOpts.ListOptions.Page = resp.NextPage
+	}
+
+	return counts
+}
+
+func GenerateVulnerabilityReport(enterpriseSlug string, client *githubv4.Client, restClient *github.Client) error {
+	file, err := os.Create("vulnerabilities.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"owner", "name"}
+	for _, severity := range severities {
+		header = append(header, "dependabot_"+severity)
+	}
+	for _, severity := range severities {
+		header = append(header, "code_scanning_"+severity)
+	}
+	header = append(header, "secret_scanning", "total_open", "oldest_open_alert", "oldest_alert_age_days", "risk_score", "unknown_sources")
+
+	err = writer.Write(header)
+	if err != nil {
+		panic(err)
+	}
+
+	orgs, err := getEnterpriseOrgs(enterpriseSlug, client)
+	if err != nil {
+		panic(err)
+	}
+
+	allCounts := []*AlertCounts{}
+	start := time.Now()
+	for _, org := range orgs {
+		repos, err := getOrgRepos(string(org.Login), false, client)
+		if err != nil {
+			panic(err)
+		}
+
+		log.Printf("Fetching open alerts for %d repositories in %s", len(repos), org.Login)
+		for _, repo := range repos {
+			// archived repositories are read-only and no longer scanned
+			if repo.IsArchived {
+				continue
+			}
+
+			allCounts = append(allCounts, getRepoAlertCounts(string(org.Login), repo.Name, restClient))
+		}
+	}
+	log.Printf("Fetched all alerts in %v", time.Since(start))
+
+	// highest risk first, the oldest open alert breaks ties
+	sort.SliceStable(allCounts, func(i, j int) bool {
+		if allCounts[i].RiskScore() != allCounts[j].RiskScore() {
+			return allCounts[i].RiskScore() > allCounts[j].RiskScore()
+		}
+		return allCounts[i].OldestOpen.Before(allCounts[j].OldestOpen)
+	})
+
+	now := time.Now()
+	unknown := 0
+	for _, counts := range allCounts {
+		// counts of sources that could not be read are written as unknown rather than 0
+		count := func(source string, n int) string {
+			if contains(counts.Unknown, source) {
+				return "unknown"
+			}
+			return fmt.Sprintf("%d", n)
+		}
+		if len(counts.Unknown) > 0 {
+			unknown++
+		}
+
+		record := []string{counts.Owner, counts.Name}
+		for _, severity := range severities {
+			record = append(record, count("dependabot", counts.Dependabot[severity]))
+		}
+		for _, severity := range severities {
+			record = append(record, count("code_scanning", counts.CodeScanning[severity]))
+		}
+
+		age := ""
+		if !counts.OldestOpen.IsZero() {
+			age = fmt.Sprintf("%d", int(now.Sub(counts.OldestOpen).Hours()/24))
+		}
+
+		record = append(record,
+			count("secret_scanning", counts.SecretScanning),
+			fmt.Sprintf("%d", counts.Total()),
+			formatTime(counts.OldestOpen),
+			age,
+			fmt.Sprintf("%d", counts.RiskScore()),
+			strings.Join(counts.Unknown, " "),
+		)
+
+		err = writer.Write(record)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if unknown > 0 {
+		log.Printf("%d repositories have alert sources that could not be read, check the token's scopes", unknown)
+	}
+	log.Printf("Wrote %d records to vulnerabilities.csv", len(allCounts))
+
+	return nil
+}